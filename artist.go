@@ -0,0 +1,164 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Pitrified/go-turtle"
+)
+
+// travelStep is the distance in pixels the cursor advances per frame of an
+// animated pen-up move.
+const travelStep = 4.0
+
+// artist wraps a turtle.TurtleDraw, paces its moves and draws its lines
+// itself, blending the pen color into the World image.
+type artist struct {
+	*turtle.TurtleDraw
+
+	style    penStyle  // shape of the lines
+	gradient *gradient // paint used instead of the pen color, if not nil
+	opacity  float64   // pen opacity, from 0 to 1, on top of the color alpha
+	blend    blendMode // how the pen color combines with the image
+	stroke   *stroke   // what the pen is drawing, nil when up
+
+	fillColor    color.Color // paint of filled shapes, the pen color if nil
+	fillGradient *gradient   // paint of filled shapes instead of fillColor
+	filling      []vec       // the shape being traced for filling, in world coordinates
+
+	states []turtleState // saved by Push
+
+	rec  *recording // where the drawing is recorded, if not nil
+	part string     // the part being drawn, for the recording
+
+	origin     string // the source location of the drawing calls, if not found on the stack
+	source     string // the source location of the drawing call being run, when depth > 0
+	depth      int    // how many drawing calls are being run, one within the other
+	lastSource string // the source location of the last drawing call
+	calls      int    // the drawing calls of the part so far
+
+	pace pacing
+	due  time.Time   // when the moves made so far should be done
+	stop func() bool // once it returns true, the moves no longer wait, if not nil
+}
+
+// newArtist creates an artist drawing on w.
+func newArtist(w *turtle.World, pace pacing) *artist {
+	return &artist{
+		TurtleDraw: turtle.NewTurtleDraw(w),
+		style:      defaultPenStyle,
+		opacity:    1,
+		pace:       pace,
+	}
+}
+
+// Change the pen opacity, from 0 for invisible to 1 for the color as is.
+func (a *artist) SetOpacity(o float64) {
+	a.opacity = o
+}
+
+// Change how the pen color is combined with the image.
+func (a *artist) SetBlend(m blendMode) {
+	a.blend = m
+}
+
+// Stop writing.
+func (a *artist) PenUp() {
+	a.endStroke()
+	a.TurtleDraw.PenUp()
+}
+
+// Move the turtle forward, drawing if the pen is down, and wait for the
+// time the move takes.
+func (a *artist) Forward(dist float64) {
+	defer a.enter()()
+	x0, y0 := a.X, a.Y
+	a.Turtle.Forward(dist)
+	a.trace()
+	if a.On {
+		a.line(x0, y0, a.X, a.Y)
+		a.wait(a.pace.stepDelay(dist))
+	} else {
+		a.wait(a.pace.travelDelay(dist))
+	}
+}
+
+// Move the turtle backward, drawing if the pen is down.
+func (a *artist) Backward(dist float64) {
+	a.Forward(-dist)
+}
+
+// Teleport the turtle to (x, y), drawing if the pen is down. When the pen
+// is up and travel is enabled the cursor glides there instead of jumping.
+func (a *artist) SetPos(x, y float64) {
+	defer a.enter()()
+	defer a.trace()
+	if a.On {
+		x0, y0 := a.X, a.Y
+		a.Turtle.SetPos(x, y)
+		a.line(x0, y0, x, y)
+		return
+	}
+	if !a.pace.travel || a.pace.mode == paceNone {
+		a.Turtle.SetPos(x, y)
+		return
+	}
+
+	x0, y0 := a.X, a.Y
+	dist := math.Hypot(x-x0, y-y0)
+	n := int(math.Ceil(dist / travelStep))
+	for i := 1; i <= n; i++ {
+		f := float64(i) / float64(n)
+		a.Turtle.SetPos(x0+(x-x0)*f, y0+(y-y0)*f)
+		cursor.moveTo(a.X, a.Y)
+		a.wait(a.pace.travelDelay(dist / float64(n)))
+	}
+	a.Turtle.SetPos(x, y)
+	cursor.hide()
+}
+
+// wait sleeps until the moves made so far, plus d, should be done. Keeping
+// a deadline instead of sleeping d each time stops short moves from being
+// stretched by the timer resolution.
+func (a *artist) wait(d time.Duration) {
+	if d <= 0 || a.stop != nil && a.stop() {
+		return
+	}
+	now := time.Now()
+	if a.due.Before(now) {
+		a.due = now
+	}
+	a.due = a.due.Add(d)
+	time.Sleep(time.Until(a.due))
+}
+
+// travelCursor is the position of the pen while it travels with the pen up.
+type travelCursor struct {
+	mu      sync.Mutex
+	x, y    float64
+	visible bool
+}
+
+var cursor travelCursor
+
+func (c *travelCursor) moveTo(x, y float64) {
+	c.mu.Lock()
+	c.x, c.y, c.visible = x, y, true
+	c.mu.Unlock()
+}
+
+func (c *travelCursor) hide() {
+	c.mu.Lock()
+	c.visible = false
+	c.mu.Unlock()
+}
+
+// get returns the cursor position in world coordinates, and whether it
+// should be shown.
+func (c *travelCursor) get() (x, y float64, visible bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.x, c.y, c.visible
+}
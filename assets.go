@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partGroups are assets made of several parts.
+var partGroups = []struct {
+	name  string
+	parts []string
+}{
+	{"face", []string{"eyes", "nose", "mouth"}},
+}
+
+// assetManifest describes the exported assets so they can be put back
+// together. Coordinates are canvas pixels with the origin at the top left.
+type assetManifest struct {
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Assets []asset `json:"assets"`
+}
+
+type asset struct {
+	Name   string   `json:"name"`
+	File   string   `json:"file"`
+	Parts  []string `json:"parts"`
+	X      int      `json:"x"`
+	Y      int      `json:"y"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+}
+
+// assetsCmd renders every part, and every group of parts, to its own
+// cropped transparent PNG and writes a manifest.json next to them.
+func assetsCmd(args []string) error {
+	fs := flag.NewFlagSet("assets", flag.ExitOnError)
+	out := fs.String("out", "assets", "output directory")
+	only := fs.String("parts", "", "comma separated parts or groups to export, default all")
+	padding := fs.Int("padding", 0, "transparent pixels kept around each asset")
+	fs.Parse(args)
+
+	s := newCanvas()
+	drawParts(s, pacing{mode: paceNone}, nil, nil)
+
+	type job struct {
+		name  string
+		parts []string
+	}
+	var jobs []job
+	for _, p := range parts {
+		jobs = append(jobs, job{p.name, []string{p.name}})
+	}
+	for _, g := range partGroups {
+		jobs = append(jobs, job{g.name, g.parts})
+	}
+	if *only != "" {
+		var selected []job
+		for _, name := range strings.Split(*only, ",") {
+			found := false
+			for _, j := range jobs {
+				if j.name == name {
+					selected = append(selected, j)
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown part or group %q", name)
+			}
+		}
+		jobs = selected
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	manifest := assetManifest{Width: s.bounds.Dx(), Height: s.bounds.Dy()}
+	for _, j := range jobs {
+		m := image.NewRGBA(s.bounds)
+		for _, name := range j.parts {
+			draw.Draw(m, m.Bounds(), s.layer(name).world.Image, image.Point{}, draw.Over)
+		}
+		r := opaqueBounds(m)
+		if r.Empty() {
+			continue
+		}
+		r = r.Inset(-*padding).Intersect(m.Bounds())
+
+		a := asset{
+			Name:   j.name,
+			File:   j.name + ".png",
+			Parts:  j.parts,
+			X:      r.Min.X,
+			Y:      r.Min.Y,
+			Width:  r.Dx(),
+			Height: r.Dy(),
+		}
+		if err := savePNG(filepath.Join(*out, a.File), m.SubImage(r)); err != nil {
+			return err
+		}
+		manifest.Assets = append(manifest.Assets, a)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(*out, "manifest.json"), append(data, '\n'), 0644)
+}
+
+// opaqueBounds returns the smallest rectangle holding every pixel of m that
+// is not fully transparent.
+func opaqueBounds(m *image.RGBA) image.Rectangle {
+	b := m.Bounds()
+	r := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if m.RGBAAt(x, y).A == 0 {
+				continue
+			}
+			r = r.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return r
+}
+
+// savePNG writes m to filePath as a PNG.
+func savePNG(filePath string, m image.Image) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// blendMode is how the pen color is combined with the pixels under it.
+type blendMode int
+
+const (
+	blendOver     blendMode = iota // source-over, the usual painting
+	blendMultiply                  // darkens, white leaves the image unchanged
+	blendScreen                    // lightens, black leaves the image unchanged
+	blendAdd                       // adds the colors, clamped
+	blendErase                     // removes the image where the pen passes
+)
+
+var blendModeNames = map[string]blendMode{
+	"over":     blendOver,
+	"multiply": blendMultiply,
+	"screen":   blendScreen,
+	"add":      blendAdd,
+	"erase":    blendErase,
+}
+
+func (m blendMode) String() string {
+	for name, mode := range blendModeNames {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("blendMode(%d)", int(m))
+}
+
+// parseBlendMode returns the blend mode called name.
+func parseBlendMode(name string) (blendMode, error) {
+	m, ok := blendModeNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown blend mode %q", name)
+	}
+	return m, nil
+}
+
+// premul is a premultiplied color with components from 0 to 1.
+type premul struct {
+	r, g, b, a float64
+}
+
+func toPremul(c color.Color) premul {
+	r, g, b, a := c.RGBA()
+	return premul{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, float64(a) / 0xffff}
+}
+
+func (p premul) scale(k float64) premul {
+	return premul{p.r * k, p.g * k, p.b * k, p.a * k}
+}
+
+// rgba rounds p to an 8 bit premultiplied color.
+func (p premul) rgba() color.RGBA {
+	q := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 0xff))
+	}
+	c := color.RGBA{q(p.r), q(p.g), q(p.b), q(p.a)}
+	// rounding must not leave a component above the alpha
+	if c.R > c.A {
+		c.R = c.A
+	}
+	if c.G > c.A {
+		c.G = c.A
+	}
+	if c.B > c.A {
+		c.B = c.A
+	}
+	return c
+}
+
+// blend combines the source s with the destination d, both premultiplied,
+// following the W3C compositing formulas: the mode decides the color where
+// both are opaque, and each one shows through where the other is
+// transparent. Scaling s by a coverage fades the result towards d.
+func blend(mode blendMode, d, s premul) premul {
+	// the part of each color seen where the other is missing
+	keep := func(cs, cd float64) float64 {
+		return cs*(1-d.a) + cd*(1-s.a)
+	}
+	a := s.a + d.a - s.a*d.a
+	switch mode {
+	case blendMultiply:
+		return premul{
+			s.r*d.r + keep(s.r, d.r),
+			s.g*d.g + keep(s.g, d.g),
+			s.b*d.b + keep(s.b, d.b),
+			a,
+		}
+	case blendScreen:
+		return premul{
+			s.r + d.r - s.r*d.r,
+			s.g + d.g - s.g*d.g,
+			s.b + d.b - s.b*d.b,
+			a,
+		}
+	case blendAdd:
+		return premul{
+			math.Min(1, s.r+d.r),
+			math.Min(1, s.g+d.g),
+			math.Min(1, s.b+d.b),
+			math.Min(1, s.a+d.a),
+		}
+	case blendErase:
+		return d.scale(1 - s.a)
+	}
+	return premul{
+		s.r + d.r*(1-s.a),
+		s.g + d.g*(1-s.a),
+		s.b + d.b*(1-s.a),
+		a,
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBlend(t *testing.T) {
+	var none premul
+	tests := []struct {
+		mode blendMode
+		d, s premul
+		want premul
+	}{
+		{blendOver, premul{0, 0, 1, 1}, premul{0.5, 0, 0, 0.5}, premul{0.5, 0, 0.5, 1}},
+		{blendOver, premul{0, 0, 0.5, 0.5}, premul{0.5, 0, 0, 0.5}, premul{0.5, 0, 0.25, 0.75}},
+		{blendOver, none, premul{0.5, 0, 0, 0.5}, premul{0.5, 0, 0, 0.5}},
+
+		{blendMultiply, premul{0.5, 0.5, 0.5, 1}, premul{1, 0.5, 0, 1}, premul{0.5, 0.25, 0, 1}},
+		{blendMultiply, premul{0.25, 0, 0, 0.5}, premul{1, 1, 1, 1}, premul{0.75, 0.5, 0.5, 1}},
+		{blendMultiply, none, premul{1, 0.5, 0, 1}, premul{1, 0.5, 0, 1}},
+
+		{blendScreen, premul{0.5, 0, 1, 1}, premul{0.5, 0.5, 0.5, 1}, premul{0.75, 0.5, 1, 1}},
+		{blendScreen, premul{0.5, 0, 0, 0.5}, premul{0, 0, 0.5, 0.5}, premul{0.5, 0, 0.5, 0.75}},
+		{blendScreen, none, premul{0.5, 0.5, 0.5, 1}, premul{0.5, 0.5, 0.5, 1}},
+
+		{blendAdd, premul{0.75, 0.25, 0, 0.5}, premul{0.5, 0.25, 0, 0.5}, premul{1, 0.5, 0, 1}},
+		{blendAdd, premul{0.25, 0, 0, 0.25}, premul{0, 0.25, 0, 0.25}, premul{0.25, 0.25, 0, 0.5}},
+		{blendAdd, none, premul{0.5, 0.25, 0, 0.5}, premul{0.5, 0.25, 0, 0.5}},
+
+		{blendErase, premul{0.4, 0.2, 0, 0.8}, premul{1, 0, 0, 0.25}, premul{0.3, 0.15, 0, 0.6}},
+		{blendErase, premul{0.4, 0.2, 0, 0.8}, premul{1, 0, 0, 1}, none},
+		{blendErase, none, premul{1, 0, 0, 1}, none},
+	}
+	for _, tt := range tests {
+		got := blend(tt.mode, tt.d, tt.s)
+		if !nearPremul(got, tt.want) {
+			t.Errorf("blend(%v, %v, %v) = %v, want %v", tt.mode, tt.d, tt.s, got, tt.want)
+		}
+	}
+}
+
+func nearPremul(p, q premul) bool {
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	return near(p.r, q.r) && near(p.g, q.g) && near(p.b, q.b) && near(p.a, q.a)
+}
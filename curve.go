@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+)
+
+// curveTolerance is how far, in pixels, the segments a curve is flattened
+// into may stray from it.
+const curveTolerance = 0.2
+
+// maxCurveDepth bounds the subdivision of degenerate curves.
+const maxCurveDepth = 16
+
+// Draw a cubic Bezier curve from the turtle position to (x, y), with
+// control points (c1x, c1y) and (c2x, c2y), in world coordinates. The
+// turtle ends heading along the curve.
+func (a *artist) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	defer a.enter()()
+	p0 := vec{a.X, a.Y}
+	c1, c2, p1 := vec{c1x, c1y}, vec{c2x, c2y}, vec{x, y}
+	flattenCubic(p0, c1, c2, p1, 0, func(p vec) { a.lineTo(p.x, p.y) })
+	a.headAlong(p1, c2, c1, p0)
+}
+
+// Draw a quadratic Bezier curve from the turtle position to (x, y), with
+// control point (cx, cy), in world coordinates. The turtle ends heading
+// along the curve.
+func (a *artist) QuadTo(cx, cy, x, y float64) {
+	defer a.enter()()
+	// a quadratic is the cubic with control points 2/3 of the way to c
+	p0, c, p1 := vec{a.X, a.Y}, vec{cx, cy}, vec{x, y}
+	c1 := p0.add(c.sub(p0).scale(2.0 / 3))
+	c2 := p1.add(c.sub(p1).scale(2.0 / 3))
+	flattenCubic(p0, c1, c2, p1, 0, func(p vec) { a.lineTo(p.x, p.y) })
+	a.headAlong(p1, c, p0)
+}
+
+// Draw a cubic Bezier curve with points relative to the turtle: each is
+// given as a distance forward and a distance to the left of the turtle.
+func (a *artist) Cubic(c1f, c1l, c2f, c2l, f, l float64) {
+	c1, c2, p := a.relative(c1f, c1l), a.relative(c2f, c2l), a.relative(f, l)
+	a.CubicTo(c1.x, c1.y, c2.x, c2.y, p.x, p.y)
+}
+
+// Draw a quadratic Bezier curve with points relative to the turtle, as
+// for Cubic.
+func (a *artist) Quad(cf, cl, f, l float64) {
+	c, p := a.relative(cf, cl), a.relative(f, l)
+	a.QuadTo(c.x, c.y, p.x, p.y)
+}
+
+// relative returns the world point f forward and l to the left of the
+// turtle.
+func (a *artist) relative(f, l float64) vec {
+	u := polar(1, a.Deg*math.Pi/180)
+	return vec{a.X, a.Y}.add(u.scale(f)).add(u.normal().scale(l))
+}
+
+// headAlong turns the turtle along the end tangent of a curve ending at
+// end, whose other points are given from the end backwards: the first one
+// distinct from end gives the direction.
+func (a *artist) headAlong(end vec, before ...vec) {
+	for _, p := range before {
+		if d := end.sub(p); d.length() > 1e-9 {
+			a.SetHeading(d.angle() * 180 / math.Pi)
+			return
+		}
+	}
+}
+
+// lineTo moves the turtle straight to (x, y), drawing if the pen is down,
+// and waits for the time the move takes, as Forward does.
+func (a *artist) lineTo(x, y float64) {
+	defer a.enter()()
+	x0, y0 := a.X, a.Y
+	a.Turtle.SetPos(x, y)
+	a.trace()
+	dist := math.Hypot(x-x0, y-y0)
+	if a.On {
+		a.line(x0, y0, x, y)
+		a.wait(a.pace.stepDelay(dist))
+	} else {
+		a.wait(a.pace.travelDelay(dist))
+	}
+}
+
+// flattenCubic calls lineTo with the end points of segments following the
+// cubic curve from p0 to p3 within curveTolerance, splitting it in halves
+// until the control points are close enough to the chord.
+func flattenCubic(p0, p1, p2, p3 vec, depth int, lineTo func(vec)) {
+	if depth >= maxCurveDepth || cubicFlat(p0, p1, p2, p3) {
+		lineTo(p3)
+		return
+	}
+	// de Casteljau split at t = 1/2
+	mid := func(a, b vec) vec { return a.add(b).scale(0.5) }
+	p01, p12, p23 := mid(p0, p1), mid(p1, p2), mid(p2, p3)
+	p012, p123 := mid(p01, p12), mid(p12, p23)
+	m := mid(p012, p123)
+	flattenCubic(p0, p01, p012, m, depth+1, lineTo)
+	flattenCubic(m, p123, p23, p3, depth+1, lineTo)
+}
+
+// cubicFlat reports whether both control points of a cubic lie within
+// curveTolerance of its chord, which bounds how far the curve strays from
+// it.
+func cubicFlat(p0, p1, p2, p3 vec) bool {
+	d := p3.sub(p0)
+	l := d.length()
+	if l < 1e-9 {
+		return p1.sub(p0).length() <= curveTolerance && p2.sub(p0).length() <= curveTolerance
+	}
+	for _, p := range []vec{p1, p2} {
+		v := p.sub(p0)
+		// off the chord, or along it but past its ends
+		if math.Abs(v.cross(d))/l > curveTolerance {
+			return false
+		}
+		if t := v.dot(d) / l; t < -curveTolerance || t > l+curveTolerance {
+			return false
+		}
+	}
+	return true
+}
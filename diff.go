@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+)
+
+// exitStatus is an error that makes the program exit with its status and
+// no message, for commands whose result is their exit status.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// imageDiff is how two images of the same size differ.
+type imageDiff struct {
+	changed  int     // pixels with a channel differing by more than the tolerance
+	maxDelta int     // largest difference of a channel, out of 255
+	psnr     float64 // peak signal to noise ratio in dB, +Inf if identical
+	ssim     float64 // structural similarity of the brightness, 1 if identical
+	image    *image.RGBA
+}
+
+// compareImages compares got against want, taking channels differing by
+// at most tolerance, out of 255, as equal. Its image is want, faded, with
+// the changed pixels in red. Images with no pixels cannot be compared.
+func compareImages(got, want image.Image, tolerance int) (imageDiff, error) {
+	b := want.Bounds()
+	if b.Empty() {
+		return imageDiff{}, errors.New("the images have no pixels")
+	}
+	d := imageDiff{image: image.NewRGBA(b)}
+	draw.Draw(d.image, b, want, b.Min, draw.Src)
+	draw.Draw(d.image, b, &image.Uniform{color.NRGBA{0xff, 0xff, 0xff, 0xc0}}, image.Point{}, draw.Over)
+
+	gb := got.Bounds()
+	var se float64
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			g := color.NRGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y)).(color.NRGBA)
+			w := color.NRGBAModel.Convert(want.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			delta := 0
+			for _, c := range [][2]uint8{{g.R, w.R}, {g.G, w.G}, {g.B, w.B}, {g.A, w.A}} {
+				dc := int(c[0]) - int(c[1])
+				se += float64(dc * dc)
+				if dc < 0 {
+					dc = -dc
+				}
+				if dc > delta {
+					delta = dc
+				}
+			}
+			if delta > d.maxDelta {
+				d.maxDelta = delta
+			}
+			if delta > tolerance {
+				d.changed++
+				d.image.Set(b.Min.X+x, b.Min.Y+y, color.NRGBA{0xff, 0, 0, 0xff})
+			}
+		}
+	}
+	mse := se / float64(4*b.Dx()*b.Dy())
+	d.psnr = math.Inf(1)
+	if mse > 0 {
+		d.psnr = 10 * math.Log10(255*255/mse)
+	}
+	d.ssim = ssim(got, want)
+	return d, nil
+}
+
+// ssimWindow is the side of the squares SSIM is computed on.
+const ssimWindow = 8
+
+// ssim returns the mean structural similarity of the brightness of a and b
+// over squares of ssimWindow pixels.
+func ssim(a, b image.Image) float64 {
+	ab, bb := a.Bounds(), b.Bounds()
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+	lum := func(m image.Image, x, y int) float64 {
+		return luma(color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA))
+	}
+	total, n := 0.0, 0
+	for y0 := 0; y0+ssimWindow <= bb.Dy(); y0 += ssimWindow {
+		for x0 := 0; x0+ssimWindow <= bb.Dx(); x0 += ssimWindow {
+			var sa, sb, saa, sbb, sab float64
+			for y := y0; y < y0+ssimWindow; y++ {
+				for x := x0; x < x0+ssimWindow; x++ {
+					va := lum(a, ab.Min.X+x, ab.Min.Y+y)
+					vb := lum(b, bb.Min.X+x, bb.Min.Y+y)
+					sa, sb = sa+va, sb+vb
+					saa, sbb, sab = saa+va*va, sbb+vb*vb, sab+va*vb
+				}
+			}
+			k := float64(ssimWindow * ssimWindow)
+			ma, mb := sa/k, sb/k
+			va, vb := saa/k-ma*ma, sbb/k-mb*mb
+			cov := sab/k - ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return total / float64(n)
+}
+
+// loadImage decodes the PNG or JPEG image at path.
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// diffCmd compares two images. It exits with status 0 if they match, 1 if
+// they differ and 2 if they cannot be compared.
+func diffCmd(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: bdd-go diff [flags] old.png new.png")
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "diff.png", "visual diff written when the images differ, empty for none")
+	tolerance := fs.Int("tolerance", 0, "largest difference of a color channel, out of 255, still taken as a match")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	want, err := loadImage(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitStatus(2)
+	}
+	got, err := loadImage(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitStatus(2)
+	}
+	if ws, gs := want.Bounds().Size(), got.Bounds().Size(); ws != gs {
+		fmt.Fprintf(os.Stderr, "sizes differ: %v and %v\n", ws, gs)
+		return exitStatus(2)
+	}
+
+	d, err := compareImages(got, want, *tolerance)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitStatus(2)
+	}
+	total := want.Bounds().Dx() * want.Bounds().Dy()
+	fmt.Printf("changed pixels: %d of %d (%.3f%%)\n", d.changed, total, 100*float64(d.changed)/float64(total))
+	fmt.Printf("max channel delta: %d\n", d.maxDelta)
+	fmt.Printf("PSNR: %.2f dB\n", d.psnr)
+	fmt.Printf("SSIM: %.5f\n", d.ssim)
+	if d.changed == 0 {
+		return nil
+	}
+	if *out != "" {
+		if err := savePNG(*out, d.image); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitStatus(2)
+		}
+		fmt.Printf("diff: %s\n", *out)
+	}
+	return exitStatus(1)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image/color"
+)
+
+// Change the color shapes are filled with, replacing any fill gradient. A
+// nil color fills with the pen color.
+func (a *artist) SetFillColor(c color.Color) {
+	a.fillColor = c
+	a.fillGradient = nil
+}
+
+// Fill shapes with the gradient g instead of a solid color. Gradients
+// running along strokes have no meaning for an area, and fill with the
+// color of their first stop.
+func (a *artist) SetFillGradient(g *gradient) {
+	a.fillGradient = g
+}
+
+// Start tracing a shape to fill: every move the turtle makes from here,
+// with the pen up or down, adds a corner to it.
+func (a *artist) BeginFill() {
+	a.filling = []vec{{a.X, a.Y}}
+}
+
+// Fill the shape traced since BeginFill, closing it back to where it
+// started, with the fill paint and the pen opacity and blend mode. The fill
+// is composited over what is already drawn, so callers wanting an outline
+// fill the shape before they stroke it.
+func (a *artist) EndFill() {
+	points := a.filling
+	a.filling = nil
+	if len(points) < 3 {
+		return
+	}
+	a.endStroke()
+
+	c := a.fillColor
+	if c == nil {
+		c = a.Color
+	}
+	s := newStroke(strokeKey{
+		world:    a.W,
+		img:      a.W.Image,
+		color:    c,
+		gradient: a.fillGradient,
+		opacity:  a.opacity,
+		blend:    a.blend,
+	}, a.style)
+	shape := make(polygon, len(points))
+	for i, p := range points {
+		shape[i] = a.toImage(p.x, p.y)
+	}
+	if mask, r := s.rasterize([]polygon{shape}); mask != nil {
+		s.addMask(mask, r.Min)
+		s.refresh(r)
+	}
+
+	if a.rec != nil {
+		r := a.newRecord(recordFill)
+		r.color, r.gradient = c, a.fillGradient
+		r.points = points
+		a.rec.add(r)
+	}
+}
+
+// trace adds the turtle position to the shape being filled, if any.
+func (a *artist) trace() {
+	if a.filling != nil {
+		a.filling = append(a.filling, vec{a.X, a.Y})
+	}
+}
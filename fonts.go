@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gioui.org/font/gofont"
+	"gioui.org/font/opentype"
+	"gioui.org/text"
+	"golang.org/x/image/font/sfnt"
+)
+
+// loadedFont is a face read from a font file.
+type loadedFont struct {
+	name string
+	ui   text.Face
+}
+
+var (
+	loadedFontsMu sync.Mutex
+	loadedFonts   []loadedFont
+
+	// fallbackFaces are the faces tried, in order, for the runes missing
+	// from the face text is written with.
+	fallbackFaces []string
+)
+
+// loadFontFiles loads the comma separated list of TTF, OTF, TTC or OTC
+// files, registering every face they hold and adding them to the fallback
+// chain.
+func loadFontFiles(list string) error {
+	if list == "" {
+		return nil
+	}
+	for _, path := range strings.Split(list, ",") {
+		names, err := loadFontFile(path)
+		if err != nil {
+			return err
+		}
+		fallbackFaces = append(fallbackFaces, names...)
+	}
+	return nil
+}
+
+// loadFontFile registers the faces in the font file at path, for writing on
+// the canvas and for the window, and returns their names. A face is named
+// after its family, followed by its subfamily unless that is Regular, or
+// after the file when the font has no names.
+func loadFontFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := sfnt.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	uiColl, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var names []string
+	for i := 0; i < coll.NumFonts(); i++ {
+		f, err := coll.Font(i)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		ui, err := uiColl.Font(i)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		name := faceName(f)
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			if coll.NumFonts() > 1 {
+				name = fmt.Sprintf("%s %d", name, i)
+			}
+		}
+		registerFace(name, f, ui)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// faceName returns the family and subfamily names of f.
+func faceName(f *sfnt.Font) string {
+	var buf sfnt.Buffer
+	family, err := f.Name(&buf, sfnt.NameIDFamily)
+	if err != nil {
+		return ""
+	}
+	sub, err := f.Name(&buf, sfnt.NameIDSubfamily)
+	if err != nil || sub == "Regular" {
+		return family
+	}
+	return family + " " + sub
+}
+
+// registerFace makes f available to Write as name, and ui to the window.
+func registerFace(name string, f *sfnt.Font, ui text.Face) {
+	parsedFacesMu.Lock()
+	parsedFaces[name] = f
+	parsedFacesMu.Unlock()
+
+	loadedFontsMu.Lock()
+	loadedFonts = append(loadedFonts, loadedFont{name: name, ui: ui})
+	loadedFontsMu.Unlock()
+}
+
+// fontChain returns the face called name followed by the fallback faces.
+func fontChain(name string) ([]*sfnt.Font, error) {
+	f, err := fontFace(name)
+	if err != nil {
+		return nil, err
+	}
+	chain := []*sfnt.Font{f}
+	for _, fb := range fallbackFaces {
+		if fb == name {
+			continue
+		}
+		f, err := fontFace(fb)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, f)
+	}
+	return chain, nil
+}
+
+// faceFor returns the first face of chain with a glyph for r, or the first
+// face if none has one so it draws its missing glyph box.
+func faceFor(buf *sfnt.Buffer, chain []*sfnt.Font, r rune) *sfnt.Font {
+	for _, f := range chain {
+		if idx, err := f.GlyphIndex(buf, r); err == nil && idx != 0 {
+			return f
+		}
+	}
+	return chain[0]
+}
+
+// uiFonts returns the faces of the window theme. The first loaded font
+// becomes the default typeface, since a CJK font usually covers Latin as
+// well while the Go fonts have no CJK glyphs; the other loaded fonts can
+// be selected by name.
+func uiFonts() []text.FontFace {
+	loadedFontsMu.Lock()
+	defer loadedFontsMu.Unlock()
+
+	var faces []text.FontFace
+	for _, f := range loadedFonts {
+		faces = append(faces, text.FontFace{
+			Font: text.Font{Typeface: text.Typeface(f.name)},
+			Face: f.ui,
+		})
+	}
+	return append(faces, gofont.Collection()...)
+}
@@ -5,4 +5,5 @@ go 1.16
 require (
 	gioui.org v0.0.0-20220213104729-2f17e5c8c7a1
 	github.com/Pitrified/go-turtle v0.3.0
+	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d
 )
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// goldenDir holds the reference renderings TestGolden compares the drawing
+// against: the whole mascot, and each part on its own.
+const goldenDir = "testdata/golden"
+
+var (
+	update    = flag.Bool("update", false, "write the current rendering as the new goldens")
+	tolerance = flag.Int("tolerance", 0, "largest difference of a color channel, out of 255, still taken as a match")
+	diffDir   = flag.String("diff", "golden-diff", "directory the diff images of failed comparisons are written to")
+)
+
+// goldenImages renders the drawing headlessly and returns the images kept
+// as goldens, by file name.
+func goldenImages() map[string]*image.RGBA {
+	s := newCanvas()
+	defer s.close()
+	drawParts(s, pacing{mode: paceNone}, nil, nil)
+	images := map[string]*image.RGBA{"bdd-go.png": s.compositeOf(nil)}
+	for _, name := range partNames() {
+		images[name+".png"] = s.layer(name).world.Image
+	}
+	return images
+}
+
+// TestGolden checks the drawing against the golden images, or regenerates
+// them with -update.
+func TestGolden(t *testing.T) {
+	images := goldenImages()
+	names := append([]string{"bdd-go.png"}, partNames()...)
+	for i := 1; i < len(names); i++ {
+		names[i] += ".png"
+	}
+
+	if *update {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			if err := savePNG(filepath.Join(goldenDir, name), images[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Logf("wrote %d goldens to %s", len(names), goldenDir)
+		return
+	}
+
+	for _, name := range names {
+		want, err := loadImage(filepath.Join(goldenDir, name))
+		if err != nil {
+			t.Fatalf("%v (run go test -run TestGolden -update to create the goldens)", err)
+		}
+		got := images[name]
+		if got.Bounds() != want.Bounds() {
+			t.Errorf("%s: size %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+			continue
+		}
+		d, err := compareImages(got, want, *tolerance)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if d.changed == 0 {
+			continue
+		}
+		if err := os.MkdirAll(*diffDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(*diffDir, name)
+		if err := savePNG(path, d.image); err != nil {
+			t.Fatal(err)
+		}
+		t.Errorf("%s: %d pixels differ, see %s", name, d.changed, path)
+	}
+}
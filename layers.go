@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/Pitrified/go-turtle"
+)
+
+// layer is a transparent image holding one part of the mascot.
+type layer struct {
+	name    string
+	world   *turtle.World
+	visible bool
+	opacity float64 // 0 to 1
+	z       int     // layers with a higher z are drawn on top
+
+	// controls in the window
+	show  widget.Bool
+	alpha widget.Float
+}
+
+// layerStack is the ordered set of layers making up the drawing, over a
+// solid background.
+type layerStack struct {
+	mu         sync.Mutex
+	background color.Color
+	bounds     image.Rectangle
+	layers     []*layer
+	rec        *recording // what was drawn on the layers, for vector export
+}
+
+// newLayerStack creates a stack with one empty layer per name, in order.
+func newLayerStack(width, height int, background color.Color, names []string) *layerStack {
+	s := &layerStack{
+		background: background,
+		bounds:     image.Rect(0, 0, width, height),
+		rec:        &recording{},
+	}
+	for i, name := range names {
+		l := &layer{
+			name:    name,
+			world:   turtle.NewWorldWithImage(image.NewRGBA(s.bounds)),
+			visible: true,
+			opacity: 1,
+			z:       i,
+		}
+		l.show.Value = l.visible
+		l.alpha.Value = float32(l.opacity)
+		s.layers = append(s.layers, l)
+	}
+	return s
+}
+
+// close stops the goroutines of the worlds of the layers, once the stack
+// is no longer drawn on. It is called at most once.
+func (s *layerStack) close() {
+	for _, l := range s.layers {
+		l.world.Close()
+	}
+}
+
+// layer returns the named layer, or nil.
+func (s *layerStack) layer(name string) *layer {
+	for _, l := range s.layers {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// ordered returns the layers from bottom to top.
+func (s *layerStack) ordered() []*layer {
+	ls := append([]*layer(nil), s.layers...)
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i].z < ls[j].z })
+	return ls
+}
+
+// composite flattens the visible layers onto the background.
+func (s *layerStack) composite() *image.RGBA {
+	return s.compositeOf(nil)
+}
+
+// compositeOf flattens the named layers onto the background, whether they
+// are visible or not. A nil names means the visible layers.
+func (s *layerStack) compositeOf(names []string) *image.RGBA {
+	return s.flatten(names, nil)
+}
+
+// compositeWith flattens the visible layers onto the background, with the
+// reference image under or over them. ref may be nil.
+func (s *layerStack) compositeWith(ref *reference) *image.RGBA {
+	return s.flatten(nil, ref)
+}
+
+func (s *layerStack) flatten(names []string, ref *reference) *image.RGBA {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := image.NewRGBA(s.bounds)
+	draw.Draw(m, m.Bounds(), &image.Uniform{s.background}, image.Point{}, draw.Src)
+	if ref != nil && !ref.above {
+		ref.draw(m)
+	}
+	for _, l := range s.ordered() {
+		if names == nil && !l.visible || names != nil && !contains(names, l.name) {
+			continue
+		}
+		drawLayer(m, l.world.Image, l.opacity)
+	}
+	if ref != nil && ref.above {
+		ref.draw(m)
+	}
+	return m
+}
+
+// drawLayer draws src over dst with the given opacity.
+func drawLayer(dst draw.Image, src image.Image, opacity float64) {
+	switch {
+	case opacity <= 0:
+		return
+	case opacity >= 1:
+		draw.Draw(dst, dst.Bounds(), src, image.Point{}, draw.Over)
+	default:
+		mask := image.NewUniform(color.Alpha{A: uint8(opacity*0xff + 0.5)})
+		draw.DrawMask(dst, dst.Bounds(), src, image.Point{}, mask, image.Point{}, draw.Over)
+	}
+}
+
+// save writes the composite of the named layers to filePath as a PNG.
+func (s *layerStack) save(filePath string, names []string) error {
+	return savePNG(filePath, s.compositeOf(names))
+}
+
+// clear erases the named layer and what was recorded on it.
+func (s *layerStack) clear(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l := s.layer(name); l != nil {
+		draw.Draw(l.world.Image, l.world.Image.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	}
+	s.rec.removePart(name)
+}
+
+// setVisible shows or hides the named layer.
+func (s *layerStack) setVisible(name string, visible bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l := s.layer(name); l != nil {
+		l.visible = visible
+		l.show.Value = visible
+	}
+}
+
+// layout draws a checkbox and an opacity slider for every layer, top layer
+// first, and applies the changes made with them.
+func (s *layerStack) layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gtx.Constraints.Min = image.Point{}
+	ls := s.ordered()
+	var rows []layout.FlexChild
+	for i := len(ls) - 1; i >= 0; i-- {
+		l := ls[i]
+		if l.show.Changed() {
+			l.visible = l.show.Value
+		}
+		if l.alpha.Changed() {
+			l.opacity = float64(l.alpha.Value)
+		}
+		rows = append(rows,
+			layout.Rigid(material.CheckBox(th, &l.show, l.name).Layout),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Max.X = gtx.Px(unit.Dp(120))
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				return material.Slider(th, &l.alpha, 0, 1).Layout(gtx)
+			}),
+		)
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// applyLayerFlags sets the visibility, opacity and z-order of the layers
+// from comma separated flag values.
+func (s *layerStack) applyLayerFlags(hidden, opacity, z string) error {
+	names, err := layerNames(s, hidden)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		s.setVisible(name, false)
+	}
+
+	err = forEachSetting(s, opacity, func(l *layer, value string) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("bad opacity for layer %s: %v", l.name, err)
+		}
+		if v < 0 || v > 1 {
+			return fmt.Errorf("opacity of layer %s out of range: %v", l.name, v)
+		}
+		l.opacity = v
+		l.alpha.Value = float32(v)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return forEachSetting(s, z, func(l *layer, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("bad z-order for layer %s: %q is not an integer", l.name, value)
+		}
+		l.z = v
+		return nil
+	})
+}
+
+// layerNames splits a comma separated list of layer names, checking that
+// each one exists. An empty list gives nil.
+func layerNames(s *layerStack, list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	names := strings.Split(list, ",")
+	for _, name := range names {
+		if s.layer(name) == nil {
+			return nil, fmt.Errorf("unknown layer %q", name)
+		}
+	}
+	return names, nil
+}
+
+// forEachSetting calls f for every name=value pair in the comma separated
+// list.
+func forEachSetting(s *layerStack, list string, f func(l *layer, value string) error) error {
+	if list == "" {
+		return nil
+	}
+	for _, item := range strings.Split(list, ",") {
+		i := strings.IndexByte(item, '=')
+		if i < 0 {
+			return fmt.Errorf("bad layer setting %q, want layer=value", item)
+		}
+		l := s.layer(item[:i])
+		if l == nil {
+			return fmt.Errorf("unknown layer %q", item[:i])
+		}
+		if err := f(l, item[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Pitrified/go-turtle"
+)
+
+// production is one way to rewrite a symbol. A symbol with several
+// productions picks one at random, in proportion to their weights.
+type production struct {
+	weight      float64
+	replacement string
+}
+
+// lsystem is a Lindenmayer system drawn by the turtle. After expansion the
+// symbols are read as turtle commands:
+//
+//	F G   move forward drawing
+//	f     move forward without drawing
+//	+ -   turn left, right by angle
+//	|     turn around
+//	[ ]   save, restore the turtle state
+//
+// Other symbols only take part in the rewriting.
+type lsystem struct {
+	axiom      string
+	rules      map[rune][]production
+	iterations int
+	angle      float64 // in degrees
+	heading    float64 // starting heading, in degrees
+	step       float64 // length of a forward move, when not fitting a box
+	color      color.Color
+	gradient   *gradient // painted instead of color, if not nil
+}
+
+// expand applies the rules iterations times to the axiom, drawing
+// stochastic choices from rng.
+func (l *lsystem) expand(rng *rand.Rand) string {
+	s := l.axiom
+	for i := 0; i < l.iterations; i++ {
+		var b strings.Builder
+		for _, r := range s {
+			ps, ok := l.rules[r]
+			if !ok {
+				b.WriteRune(r)
+				continue
+			}
+			b.WriteString(pick(ps, rng))
+		}
+		s = b.String()
+	}
+	return s
+}
+
+// maxLSystemLength is the most symbols an L-system may expand to.
+const maxLSystemLength = 1 << 20
+
+// maxLength returns an upper bound of the length of the expansion, at
+// most maxLSystemLength+1, without expanding.
+func (l *lsystem) maxLength() int {
+	count := make(map[rune]int)
+	for _, r := range l.axiom {
+		count[r]++
+	}
+	for i := 0; i < l.iterations; i++ {
+		next := make(map[rune]int)
+		for r, n := range count {
+			ps, ok := l.rules[r]
+			if !ok {
+				next[r] += n
+				continue
+			}
+			// the most of each symbol any production makes
+			most := make(map[rune]int)
+			for _, p := range ps {
+				in := make(map[rune]int)
+				for _, s := range p.replacement {
+					in[s]++
+				}
+				for s, k := range in {
+					if k > most[s] {
+						most[s] = k
+					}
+				}
+			}
+			for s, k := range most {
+				next[s] += n * k
+				if next[s] > maxLSystemLength {
+					return maxLSystemLength + 1
+				}
+			}
+		}
+		count = next
+	}
+	total := 0
+	for _, n := range count {
+		total += n
+	}
+	if total > maxLSystemLength {
+		return maxLSystemLength + 1
+	}
+	return total
+}
+
+// pick chooses one of ps at random, weighted.
+func pick(ps []production, rng *rand.Rand) string {
+	if len(ps) == 1 {
+		return ps[0].replacement
+	}
+	total := 0.0
+	for _, p := range ps {
+		total += p.weight
+	}
+	x := rng.Float64() * total
+	for _, p := range ps {
+		if x < p.weight {
+			return p.replacement
+		}
+		x -= p.weight
+	}
+	return ps[len(ps)-1].replacement
+}
+
+// bounds returns the box covered by the turtle reading program with unit
+// steps, starting at the origin.
+func (l *lsystem) bounds(program string) (min, max vec) {
+	t := turtle.Turtle{Deg: l.heading}
+	var stack []turtle.Turtle
+	for _, r := range program {
+		switch r {
+		case 'F', 'G', 'f':
+			t.Forward(1)
+		case '+':
+			t.Left(l.angle)
+		case '-':
+			t.Right(l.angle)
+		case '|':
+			t.Left(180)
+		case '[':
+			stack = append(stack, t)
+		case ']':
+			if len(stack) > 0 {
+				t = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			continue
+		}
+		min = vec{math.Min(min.x, t.X), math.Min(min.y, t.Y)}
+		max = vec{math.Max(max.x, t.X), math.Max(max.y, t.Y)}
+	}
+	return min, max
+}
+
+// draw expands the system and runs it with t. If box is not empty the
+// drawing is scaled and centered to fit it, in world coordinates;
+// otherwise it starts at the turtle position with the system step.
+func (l *lsystem) draw(t *artist, box image.Rectangle, rng *rand.Rand) {
+	program := l.expand(rng)
+	step := l.step
+	x, y := t.X, t.Y
+	if !box.Empty() {
+		min, max := l.bounds(program)
+		size := max.sub(min)
+		step = math.Inf(1)
+		if size.x > 0 {
+			step = float64(box.Dx()) / size.x
+		}
+		if size.y > 0 {
+			step = math.Min(step, float64(box.Dy())/size.y)
+		}
+		if math.IsInf(step, 1) {
+			step = l.step
+		}
+		// center the scaled drawing in the box
+		x = float64(box.Min.X) + (float64(box.Dx())-size.x*step)/2 - min.x*step
+		y = float64(box.Min.Y) + (float64(box.Dy())-size.y*step)/2 - min.y*step
+	}
+
+	t.PenUp()
+	t.SetPos(x, y)
+	t.SetHeading(l.heading)
+	if l.color != nil {
+		t.SetColor(l.color)
+	}
+	if l.gradient != nil {
+		t.SetGradient(l.gradient)
+	}
+	t.PenDown()
+	for _, r := range program {
+		switch r {
+		case 'F', 'G':
+			t.Forward(step)
+		case 'f':
+			t.PenUp()
+			t.Forward(step)
+			t.PenDown()
+		case '+':
+			t.Left(l.angle)
+		case '-':
+			t.Right(l.angle)
+		case '|':
+			t.Left(180)
+		case '[':
+			t.Push()
+		case ']':
+			t.Pop()
+		}
+	}
+	t.PenUp()
+}
+
+// rule returns the deterministic production of a symbol.
+func rule(replacement string) []production {
+	return []production{{1, replacement}}
+}
+
+// lsystemPresets are the systems that can be drawn by name.
+var lsystemPresets = map[string]lsystem{
+	"koch": {
+		axiom:      "F--F--F",
+		rules:      map[rune][]production{'F': rule("F+F--F+F")},
+		iterations: 4,
+		angle:      60,
+		step:       4,
+		color:      turtle.Blue,
+	},
+	"dragon": {
+		axiom:      "FX",
+		rules:      map[rune][]production{'X': rule("X+YF+"), 'Y': rule("-FX-Y")},
+		iterations: 10,
+		angle:      90,
+		step:       6,
+		color:      turtle.Red,
+		gradient:   alongGradient(600, evenStops(turtle.Red, turtle.DarkOrange)...).withSpread(spreadReflect),
+	},
+	"fern": {
+		axiom:      "X",
+		rules:      map[rune][]production{'X': rule("F+[[X]-X]-F[-FX]+X"), 'F': rule("FF")},
+		iterations: 5,
+		angle:      25,
+		heading:    80,
+		step:       3,
+		color:      color.RGBA{0x2e, 0x8b, 0x57, 0xff},
+	},
+	"plant": {
+		axiom: "F",
+		rules: map[rune][]production{'F': {
+			{1, "F[+F]F[-F]F"},
+			{1, "F[+F]F"},
+			{1, "F[-F]F"},
+		}},
+		iterations: 5,
+		angle:      25.7,
+		heading:    90,
+		step:       3,
+		color:      color.RGBA{0x6b, 0x8e, 0x23, 0xff},
+	},
+	"sierpinski": {
+		axiom:      "F-G-G",
+		rules:      map[rune][]production{'F': rule("F-G+F+G-F"), 'G': rule("GG")},
+		iterations: 5,
+		angle:      120,
+		step:       8,
+		color:      turtle.DarkOrange,
+	},
+	"hilbert": {
+		axiom:      "A",
+		rules:      map[rune][]production{'A': rule("+BF-AFA-FB+"), 'B': rule("-AF+BFB+FA-")},
+		iterations: 5,
+		angle:      90,
+		step:       8,
+		color:      turtle.Magenta,
+	},
+}
+
+// lsystemPreset returns a copy of the preset called name, with its
+// iterations changed if n is positive.
+func lsystemPreset(name string, n int) (*lsystem, error) {
+	p, ok := lsystemPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown L-system %q, want one of %s", name, strings.Join(lsystemPresetNames(), ", "))
+	}
+	if n > 0 {
+		p.iterations = n
+	}
+	if p.maxLength() > maxLSystemLength {
+		return nil, fmt.Errorf("L-system %s with %d iterations expands past %d symbols", name, p.iterations, maxLSystemLength)
+	}
+	return &p, nil
+}
+
+func lsystemPresetNames() []string {
+	var names []string
+	for name := range lsystemPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lsystemBox is where an L-system is drawn next to the mascot, under its
+// feet, in world coordinates.
+var lsystemBox = image.Rect(20, 10, 580, 190)
+
+// addLSystemPart adds a part drawing the L-system given as "preset" or
+// "preset:iterations" in lsystemBox, on a layer named lsystem.
+func addLSystemPart(spec string) error {
+	name, n := spec, 0
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		var err error
+		name = spec[:i]
+		if n, err = strconv.Atoi(spec[i+1:]); err != nil {
+			return fmt.Errorf("bad L-system iterations %q", spec[i+1:])
+		}
+	}
+	l, err := lsystemPreset(name, n)
+	if err != nil {
+		return err
+	}
+	addPart("lsystem", func(t *artist) {
+		t.SetSize(1)
+		l.draw(t, lsystemBox, rand.New(rand.NewSource(1)))
+	})
+	return nil
+}
+
+// lsystemCmd draws an L-system preset alone and saves it as a PNG.
+func lsystemCmd(args []string) error {
+	fs := flag.NewFlagSet("lsystem", flag.ExitOnError)
+	name := fs.String("preset", "fern", "preset to draw: "+strings.Join(lsystemPresetNames(), ", "))
+	n := fs.Int("n", 0, "iterations, default the preset's")
+	seed := fs.Int64("seed", 1, "random seed for stochastic rules")
+	out := fs.String("out", "lsystem.png", "output file")
+	fs.Parse(args)
+
+	l, err := lsystemPreset(*name, *n)
+	if err != nil {
+		return err
+	}
+	s := newLayerStack(int(width), int(hight), background, []string{"lsystem"})
+	t := newArtist(s.layer("lsystem").world, pacing{mode: paceNone})
+	t.SetSize(1)
+	margin := 20
+	l.draw(t, image.Rect(margin, margin, int(width)-margin, int(hight)-margin), rand.New(rand.NewSource(*seed)))
+	return s.save(*out, nil)
+}
@@ -1,20 +1,22 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"image/color"
 	"log"
 	"math"
+	"net"
 	"os"
-	"time"
+	"sync/atomic"
 
 	"gioui.org/app"
 	"gioui.org/f32"
-	"gioui.org/font/gofont"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
-	"gioui.org/text"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 	"github.com/Pitrified/go-turtle"
@@ -27,46 +29,288 @@ const (
 )
 
 var (
-	black     = color.NRGBA{A: 0xFF}
-	world     *turtle.World
-	completed bool
-)
-
-func main() {
-	background := color.RGBA{
+	background = color.RGBA{
 		R: 0xe0,
 		G: 0xe0,
 		B: 0xe0,
 		A: 0xf0,
 	}
-	world = turtle.NewWorldWithColor(int(width), int(hight), background)
+	black       = color.NRGBA{A: 0xFF}
+	cursorColor = color.NRGBA{R: 0xd0, G: 0x20, B: 0x20, A: 0xFF}
+	canvas      *layerStack
+	completed   int32         // set to 1 once the drawing shown is done, atomically
+	inspect     *inspector    // reports the drawing call under clicks, if not nil
+	guides      *overlay      // grid, rulers and cursor readout, if not nil
+	onion       *reference    // image shown with the drawing to trace over, if not nil
+	watcher     *sceneWatcher // draws the scene file again when it changes, if not nil
+	captionText = "BEIJING 2022"
+)
+
+// parts lists the parts of the mascot in drawing order.
+var parts = []struct {
+	name string
+	draw func(t *artist)
+}{
+	{"body", body},
+	{"eyes", eyes},
+	{"nose", nose},
+	{"mouth", mouth},
+	{"redHeart", redHeart},
+	{"fiveRings", fiveRings},
+	{"rainbowCircle", rainbowCircle},
+	{"caption", caption},
+}
+
+// addPart appends a part drawn after the mascot, on a layer of its own.
+func addPart(name string, draw func(t *artist)) {
+	parts = append(parts, struct {
+		name string
+		draw func(t *artist)
+	}{name, draw})
+}
+
+// partByName returns the drawing function of the named part, or nil.
+func partByName(name string) func(t *artist) {
+	for _, p := range parts {
+		if p.name == name {
+			return p.draw
+		}
+	}
+	return nil
+}
+
+// partNames returns the names of the parts in drawing order.
+func partNames() []string {
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	return names
+}
+
+// newCanvas creates an empty layer stack with one layer per part.
+func newCanvas() *layerStack {
+	return newLayerStack(int(width), int(hight), background, partNames())
+}
+
+// drawParts draws every part on its own layer of s, with the pacing of
+// partPacing for the parts listed there and pace for the others. Once stop
+// returns true it no longer waits, and returns errStopped before the next
+// part.
+func drawParts(s *layerStack, pace pacing, partPacing map[string]pacing, stop func() bool) error {
+	t := newArtist(s.layer(parts[0].name).world, pace)
+	t.rec = s.rec
+	t.stop = stop
+	for _, p := range parts {
+		if stop != nil && stop() {
+			return errStopped
+		}
+		t.W = s.layer(p.name).world
+		t.part = p.name
+		t.calls, t.lastSource = 0, ""
+		t.pace = pace
+		if pp, ok := partPacing[p.name]; ok {
+			t.pace = pp
+		}
+		p.draw(t)
+	}
+	return nil
+}
+
+// commands are the subcommands run instead of the animation window when
+// named as the first argument.
+var commands = map[string]func(args []string) error{
+	"assets":  assetsCmd,
+	"svg":     svgCmd,
+	"lsystem": lsystemCmd,
+	"diff":    diffCmd,
+	"serve":   serveCmd,
+	"html":    htmlCmd,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				if status, ok := err.(exitStatus); ok {
+					os.Exit(int(status))
+				}
+				log.Fatal(err)
+			}
+			return
+		}
+	}
 
+	paceFlag := flag.String("pace", "step", "animation timing: step, velocity or none, with an optional value and travel, e.g. velocity:300:travel")
+	velocity := flag.Float64("velocity", 400, "pen speed in pixels per second for velocity timing")
+	travel := flag.Bool("travel", false, "animate pen-up moves as a travelling cursor")
+	partPace := flag.String("part-pace", "", "per part timing, e.g. eyes=velocity:150:travel,body=step:200")
+	hide := flag.String("hide", "", "comma separated layers to hide in the window")
+	layerOpacity := flag.String("layer-opacity", "", "per layer opacity, e.g. rainbowCircle=0.5")
+	layerZ := flag.String("layer-z", "", "per layer z-order, e.g. rainbowCircle=-1 to draw it first")
+	exportLayers := flag.String("export-layers", "", "comma separated layers to save in bdd-go.png, default all but the repl and rpc layers")
+	fonts := flag.String("font", "", "comma separated TTF/OTF/TTC files used for text the built-in fonts lack, such as CJK")
+	flag.StringVar(&captionText, "caption", captionText, "caption written under the rainbow")
+	lsys := flag.String("lsystem", "", "L-system preset drawn under the mascot, with optional iterations, e.g. fern:4")
+	importFile := flag.String("import", "", "SVG file whose shapes are drawn over the mascot")
+	traceFile := flag.String("trace", "", "PNG or JPEG image traced into lines drawn over the mascot")
+	traceMode := flag.String("trace-mode", "regions", "what to trace: regions between quantized colors, or edges")
+	traceColors := flag.Int("trace-colors", defaultTraceOptions.colors, "number of colors regions are quantized to")
+	traceDetail := flag.Float64("trace-detail", defaultTraceOptions.tolerance, "how far in pixels traced lines may be simplified, lower keeps more detail")
+	inspecting := flag.Bool("inspect", false, "report which drawing call drew the pixel clicked in the window")
+	grid := flag.Int("grid", 0, "spacing in pixels of a grid with rulers and a cursor readout drawn in the window, 0 for none; a click copies the position")
+	refFile := flag.String("ref", "", "PNG or JPEG reference image shown in the window to trace over, never exported")
+	refOpacity := flag.Float64("ref-opacity", 0.4, "opacity of the reference image, also set in the window")
+	refOffset := flag.String("ref-offset", "0,0", "turtle coordinates of the bottom left corner of the reference image, also set in the window")
+	refScale := flag.Float64("ref-scale", 1, "scale of the reference image, also set in the window")
+	refAbove := flag.Bool("ref-above", false, "show the reference image over the drawing rather than under it")
+	sceneFile := flag.String("scene", "", "scene file of turtle commands drawn instead of the mascot, and drawn again whenever it changes")
+	skipUnchanged := flag.Bool("skip-unchanged", true, "when the scene changes, draw the parts that did not change without animation")
+	replMode := flag.Bool("repl", false, "read turtle commands on stdin and draw each as it is entered, paced by -pace, on a layer over the mascot")
+	rpcAddr := flag.String("rpc", "", "serve a JSON-RPC control socket drawing on a layer over the mascot, at unix:path or localhost:port")
+	flag.Parse()
+
+	if err := loadFontFiles(*fonts); err != nil {
+		log.Fatal(err)
+	}
+
+	pace, err := parsePacing(*paceFlag, pacing{speed: speed, velocity: *velocity, travel: *travel})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *lsys != "" {
+		if err := addLSystemPart(*lsys); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *importFile != "" {
+		if err := addImportedPart(*importFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *traceFile != "" {
+		opt := defaultTraceOptions
+		mode, ok := traceModeNames[*traceMode]
+		if !ok {
+			log.Fatalf("unknown trace mode %q", *traceMode)
+		}
+		opt.mode, opt.colors, opt.tolerance = mode, *traceColors, *traceDetail
+		if err := addTracedPart(*traceFile, opt); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *replMode {
+		if *sceneFile != "" {
+			log.Fatal("-repl and -scene cannot be used together")
+		}
+		addPart(replPart, func(t *artist) {})
+	}
+	var rpcListener net.Listener
+	if *rpcAddr != "" {
+		if *sceneFile != "" {
+			log.Fatal("-rpc and -scene cannot be used together")
+		}
+		if rpcListener, err = listenRPC(*rpcAddr); err != nil {
+			log.Fatal(err)
+		}
+		addPart(rpcPart, func(t *artist) {})
+	}
+
+	canvas = newCanvas()
+	if *inspecting {
+		inspect = &inspector{}
+	}
+	if *refFile != "" {
+		var off vec
+		if _, err := fmt.Sscanf(*refOffset, "%g,%g", &off.x, &off.y); err != nil {
+			log.Fatalf("bad -ref-offset %q: want x,y", *refOffset)
+		}
+		onion, err = loadReference(*refFile, canvas.bounds, off, *refScale, *refOpacity, *refAbove)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *grid > 0 {
+		guides = &overlay{grid: *grid}
+	}
+	w := app.NewWindow(
+		app.Title("冰墩墩"),
+		app.Size(unit.Dp(300), unit.Dp(300)),
+		app.MinSize(unit.Dp(300), unit.Dp(300)),
+		//app.MaxSize(unit.Dp(600), unit.Dp(800)),
+	)
 	go func() {
-		w := app.NewWindow(
-			app.Title("冰墩墩"),
-			app.Size(unit.Dp(300), unit.Dp(300)),
-			app.MinSize(unit.Dp(300), unit.Dp(300)),
-			//app.MaxSize(unit.Dp(600), unit.Dp(800)),
-		)
 		if err := loop(w); err != nil {
 			log.Fatal(err)
 		}
 		os.Exit(0)
 	}()
 
+	if *sceneFile != "" {
+		watcher = &sceneWatcher{
+			path:          *sceneFile,
+			pace:          pace,
+			partPace:      *partPace,
+			skipUnchanged: *skipUnchanged,
+			setup: func(s *layerStack) error {
+				return s.applyLayerFlags(*hide, *layerOpacity, *layerZ)
+			},
+			invalidate: w.Invalidate,
+		}
+		setCanvas(newLayerStack(int(width), int(hight), background, nil))
+		go watcher.watch()
+		app.Main()
+		return
+	}
+
+	// every part is known by now
+	partPacing, err := parsePartPacing(*partPace, pace, partNames())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := canvas.applyLayerFlags(*hide, *layerOpacity, *layerZ); err != nil {
+		log.Fatal(err)
+	}
+	exported, err := layerNames(canvas, *exportLayers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if exported == nil {
+		// the layers drawn on live are not part of the mascot
+		for _, name := range partNames() {
+			if name != replPart && name != rpcPart {
+				exported = append(exported, name)
+			}
+		}
+	}
+
+	if *replMode {
+		go func() {
+			if err := newREPL(canvas, pace, w.Invalidate).serve(os.Stdin, os.Stdout); err != nil {
+				log.Print(err)
+			}
+			os.Exit(0)
+		}()
+	}
+
+	if rpcListener != nil {
+		ts := newTurtleService(canvas, pace, w.Invalidate)
+		log.Printf("control socket listening on %s", rpcListener.Addr())
+		go func() {
+			log.Print(serveRPC(rpcListener, ts))
+		}()
+	}
+
 	go func() {
-		// create a turtle attached to w
-		t := turtle.NewTurtleDraw(world)
-		body(t)
-		eyes(t)
-		nose(t)
-		mouth(t)
-		redHeart(t)
-		fiveRings(t)
-		rainbowCircle(t)
-		completed = true
-
-		world.SaveImage("bdd-go.png")
+		drawParts(canvas, pace, partPacing, nil)
+		atomic.StoreInt32(&completed, 1)
+
+		if err := canvas.save("bdd-go.png", exported); err != nil {
+			log.Print(err)
+		}
 	}()
 
 	app.Main()
@@ -75,7 +319,7 @@ func main() {
 func loop(w *app.Window) error {
 	//th := material.NewTheme(gofont.Collection())
 	var ops op.Ops
-	th := material.NewTheme(gofont.Collection())
+	th := material.NewTheme(uiFonts())
 	for {
 		e := <-w.Events()
 		switch e := e.(type) {
@@ -83,29 +327,44 @@ func loop(w *app.Window) error {
 			return e.Err
 		case system.FrameEvent:
 			gtx := layout.NewContext(&ops, e)
+			canvas := currentCanvas()
 
-			img := world.Image.SubImage(world.Image.Bounds())
+			img := canvas.compositeWith(onion)
 			imageOp := paint.NewImageOp(img)
 			imageOp.Add(&ops)
 			op.Affine(f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(4, 4)))
 			paint.PaintOp{}.Add(&ops)
 
-			if completed {
-				t := material.Body1(th, "BEIJING 2022")
-				t.Font = text.Font{
-					Variant: "Mono",
-					Weight:  text.Bold,
-				}
-				t.TextSize = unit.Dp(5)
-				layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
-					layout.Rigid(
-						func(gtx layout.Context) layout.Dimensions {
-							op.Offset(f32.Pt(240, 450)).Add(gtx.Ops)
-							return t.Layout(gtx)
-						},
-					),
-				)
-			} else {
+			if x, y, ok := cursor.get(); ok {
+				// world y grows upwards, window y downwards
+				c := f32.Pt(float32(x), float32(hight-y))
+				r := float32(4)
+				paint.FillShape(&ops, cursorColor, clip.Ellipse{
+					Min: c.Sub(f32.Pt(r, r)),
+					Max: c.Add(f32.Pt(r, r)),
+				}.Op(&ops))
+			}
+
+			if inspect != nil {
+				inspect.layout(gtx, th, canvas)
+			}
+			if guides != nil {
+				guides.layout(gtx, th, img)
+			}
+			if watcher != nil {
+				watcher.layout(gtx, th)
+			}
+
+			panel := op.Offset(f32.Pt(float32(width)+8, 8)).Push(gtx.Ops)
+			dims := canvas.layout(gtx, th)
+			if onion != nil {
+				below := op.Offset(f32.Pt(0, float32(dims.Size.Y)+8)).Push(gtx.Ops)
+				onion.layout(gtx, th)
+				below.Pop()
+			}
+			panel.Pop()
+
+			if atomic.LoadInt32(&completed) == 0 || atomic.LoadInt32(&animating) > 0 {
 				op.InvalidateOp{}.Add(&ops)
 			}
 
@@ -114,49 +373,24 @@ func loop(w *app.Window) error {
 	}
 }
 
-func body(t *turtle.TurtleDraw) {
+func body(t *artist) {
+	// 冰壳：先抬笔描出轮廓填充，再落笔勾线，免得填充盖住线条
+	t.PenUp()
+	t.SetPos(200, 700)
+	t.SetFillGradient(radialGradient(290, 430, 360,
+		colorStop{0.4, color.NRGBA{0xd8, 0xee, 0xfa, 0x00}},
+		colorStop{1, color.NRGBA{0x8c, 0xc8, 0xf0, 0xa0}}))
+	t.BeginFill()
+	bodyOutline(t)
+	t.EndFill()
+
 	// 头顶
 	t.PenUp()
 	t.SetPos(200, 700)
 	t.SetColor(turtle.SoftBlack)
 	t.SetSize(3)
 	t.PenDown()
-	t.SetHeading(20)
-	circle(t, 250, 35)
-	// 左耳
-	t.SetHeading(50)
-	circle(t, 42, 180)
-	// 左侧
-	t.SetHeading(-50)
-	circle(t, 190, 30)
-	circle(t, 320, 45)
-	// 左腿
-	circle(t, -120, -30)
-	circle(t, -200, -12)
-	circle(t, 18, 85)
-	circle(t, 180, 23)
-	circle(t, 20, 110)
-	circle(t, -15, -115)
-	circle(t, -100, -12)
-	// 右腿
-	circle(t, -15, -120)
-	circle(t, 15, 110)
-	circle(t, 150, 30)
-	circle(t, 15, 70)
-	circle(t, 150, 10)
-	circle(t, -200, -35)
-	circle(t, 150, 20)
-	// 右手
-	t.SetHeading(-120)
-	circle(t, -50, -30)
-	circle(t, 35, 200)
-	circle(t, 300, 23)
-	// 右侧
-	t.SetHeading(86)
-	circle(t, 300, 26)
-	// 右耳
-	t.SetHeading(122)
-	circle(t, 50, 160)
+	bodyOutline(t)
 
 	// 左手
 	t.PenUp()
@@ -236,7 +470,48 @@ func body(t *turtle.TurtleDraw) {
 	circle(t, 200, 30)
 }
 
-func eyes(t *turtle.TurtleDraw) {
+// bodyOutline traces the outline of the body, from the top of the head
+// round to where it started.
+func bodyOutline(t *artist) {
+	t.SetHeading(20)
+	circle(t, 250, 35)
+	// 左耳
+	t.SetHeading(50)
+	circle(t, 42, 180)
+	// 左侧
+	t.SetHeading(-50)
+	circle(t, 190, 30)
+	circle(t, 320, 45)
+	// 左腿
+	circle(t, -120, -30)
+	circle(t, -200, -12)
+	circle(t, 18, 85)
+	circle(t, 180, 23)
+	circle(t, 20, 110)
+	circle(t, -15, -115)
+	circle(t, -100, -12)
+	// 右腿
+	circle(t, -15, -120)
+	circle(t, 15, 110)
+	circle(t, 150, 30)
+	circle(t, 15, 70)
+	circle(t, 150, 10)
+	circle(t, -200, -35)
+	circle(t, 150, 20)
+	// 右手
+	t.SetHeading(-120)
+	circle(t, -50, -30)
+	circle(t, 35, 200)
+	circle(t, 300, 23)
+	// 右侧
+	t.SetHeading(86)
+	circle(t, 300, 26)
+	// 右耳
+	t.SetHeading(122)
+	circle(t, 50, 160)
+}
+
+func eyes(t *artist) {
 	// 右眼圈
 	t.PenUp()
 	t.SetPos(210, 620)
@@ -250,40 +525,14 @@ func eyes(t *turtle.TurtleDraw) {
 	circle(t, 100, 50)
 
 	// 右眼珠
-	t.PenUp()
-	t.SetPos(220, 610)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 25, 360)
-	t.PenUp()
-	t.SetPos(222, 603)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 19, 360)
-	t.PenUp()
-	t.SetPos(222, 597)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 10, 360)
-	t.PenUp()
-	t.SetPos(220, 580)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 5, 360)
+	ring(t, 220, 610, 25)
+	ring(t, 222, 603, 19)
+	ring(t, 222, 597, 10)
+	ring(t, 220, 580, 5)
 
 	// 左眼圈
 	t.PenUp()
 	t.SetPos(300, 585)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
 	t.PenDown()
 	t.SetHeading(120)
 	circle(t, 32, 152)
@@ -292,37 +541,13 @@ func eyes(t *turtle.TurtleDraw) {
 	circle(t, 120, 45)
 
 	// 左眼珠
-	t.PenUp()
-	t.SetPos(335, 610)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 25, 360)
-	t.PenUp()
-	t.SetPos(333, 603)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 19, 360)
-	t.PenUp()
-	t.SetPos(333, 597)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 10, 360)
-	t.PenUp()
-	t.SetPos(335, 580)
-	t.SetColor(turtle.Black)
-	t.SetSize(3)
-	t.PenDown()
-	t.SetHeading(0)
-	circle(t, 5, 360)
+	ring(t, 335, 610, 25)
+	ring(t, 333, 603, 19)
+	ring(t, 333, 597, 10)
+	ring(t, 335, 580, 5)
 }
 
-func nose(t *turtle.TurtleDraw) {
+func nose(t *artist) {
 	t.PenUp()
 	t.SetPos(290, 550)
 	t.SetColor(turtle.Black)
@@ -336,7 +561,7 @@ func nose(t *turtle.TurtleDraw) {
 	circle(t, -100, -10)
 }
 
-func mouth(t *turtle.TurtleDraw) {
+func mouth(t *artist) {
 	t.PenUp()
 	t.SetPos(245, 510)
 	t.SetColor(turtle.Black)
@@ -349,64 +574,42 @@ func mouth(t *turtle.TurtleDraw) {
 
 }
 
-func rainbowCircle(t *turtle.TurtleDraw) {
-	t.PenUp()
-	t.SetPos(135, 600)
-	t.SetColor(turtle.Cyan)
-	t.SetSize(5)
-	t.PenDown()
-	t.SetHeading(60)
-	circle(t, 165, 150)
-	circle(t, 130, 78)
-	circle(t, 250, 30)
-	circle(t, 136, 105)
-
-	t.PenUp()
-	t.SetPos(139, 596)
-	t.SetColor(turtle.Blue)
-	t.SetSize(5)
-	t.PenDown()
-	t.SetHeading(60)
-	circle(t, 160, 144)
-	circle(t, 120, 78)
-	circle(t, 242, 30)
-	circle(t, 132, 105)
-
+func rainbowCircle(t *artist) {
 	t.PenUp()
 	t.SetPos(143, 592)
-	t.SetColor(turtle.DarkOrange)
-	t.SetSize(5)
-	t.PenDown()
-	t.SetHeading(60)
-	circle(t, 155, 136)
-	circle(t, 116, 86)
-	circle(t, 220, 30)
-	circle(t, 131, 103)
-
-	t.PenUp()
-	t.SetPos(147, 588)
-	t.SetColor(turtle.Yellow)
-	t.SetSize(5)
+	t.SetWidth(20)
 	t.PenDown()
 	t.SetHeading(60)
-	circle(t, 150, 136)
-	circle(t, 104, 86)
-	circle(t, 220, 30)
-	circle(t, 125, 102)
+	// 从内到外：绿、黄、橙、蓝、青
+	bands := []color.Color{turtle.Green, turtle.Yellow, turtle.DarkOrange, turtle.Blue, turtle.Cyan}
+	rainbowArc(t, 155, 136, bands...)
+	rainbowArc(t, 116, 86, bands...)
+	rainbowArc(t, 220, 30, bands...)
+	rainbowArc(t, 131, 103, bands...)
+}
 
-	t.PenUp()
-	t.SetPos(151, 584)
-	t.SetColor(turtle.Green)
-	t.SetSize(5)
-	t.PenDown()
-	t.SetHeading(60)
-	circle(t, 145, 136)
-	circle(t, 90, 83)
-	circle(t, 220, 30)
-	circle(t, 119, 103)
+// rainbowArc draws an arc as circle does, painted with a radial gradient
+// about its center whose colors run across the pen width, from the inside
+// of the turn outwards.
+func rainbowArc(t *artist, radius, extent float64, colors ...color.Color) {
+	// the corners of the polygon circle draws lie on a circle of radius r
+	// about c, to the right of its first side
+	half := 2 * math.Pi * radius * extent / 360 / circleSteps / 2
+	turn := extent / circleSteps * math.Pi / 180
+	u := polar(1, t.Deg*math.Pi/180)
+	c := vec{t.X, t.Y}.add(u.scale(half)).sub(u.normal().scale(half / math.Tan(turn/2)))
+	r := half / math.Sin(turn/2)
+
+	inner, outer := r-t.style.width/2, r+t.style.width/2
+	stops := evenStops(colors...)
+	for i := range stops {
+		stops[i].offset = (inner + stops[i].offset*(outer-inner)) / outer
+	}
+	t.SetGradient(radialGradient(c.x, c.y, outer, stops...))
+	circle(t, radius, extent)
 }
 
-func redHeart(t *turtle.TurtleDraw) {
+func redHeart(t *artist) {
 	t.PenUp()
 	t.SetPos(490, 600)
 	t.SetColor(turtle.Red)
@@ -420,7 +623,7 @@ func redHeart(t *turtle.TurtleDraw) {
 	circle(t, 8, 180)
 }
 
-func fiveRings(t *turtle.TurtleDraw) {
+func fiveRings(t *artist) {
 	t.PenUp()
 	t.SetPos(275, 320)
 	t.SetColor(turtle.Blue)
@@ -457,16 +660,39 @@ func fiveRings(t *turtle.TurtleDraw) {
 	circle(t, 10, 360)
 }
 
-func circle(t *turtle.TurtleDraw, radius float64, extent float64) {
-	steps := 30
+func caption(t *artist) {
+	t.PenUp()
+	t.SetPos(287, 338)
+	t.SetColor(turtle.Black)
+	if err := t.Write(captionText, textFont{face: "Go Mono Bold", size: 9}, alignCenter); err != nil {
+		log.Print(err)
+	}
+}
+
+// ring draws a full circle of the given radius with the current pen,
+// starting at (x, y) heading east, and leaves the turtle as it was.
+func ring(t *artist, x, y, radius float64) {
+	t.With(func() {
+		t.PenUp()
+		t.SetPos(x, y)
+		t.PenDown()
+		t.SetHeading(0)
+		circle(t, radius, 360)
+	})
+}
+
+// circleSteps is the number of sides of the polygons circle draws.
+const circleSteps = 30
+
+func circle(t *artist, radius float64, extent float64) {
+	steps := circleSteps
 	circumference := 2 * math.Pi * radius
 	distance := circumference * extent / 360
 	step := distance / float64(steps)
 	rotation := extent / float64(steps)
 
-	for i := uint32(0); i < 30; i++ {
+	for i := 0; i < steps; i++ {
 		t.Forward(step)
 		t.Right(rotation)
-		time.Sleep(time.Second / time.Duration(speed))
 	}
 }
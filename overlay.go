@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+
+	"gioui.org/f32"
+	"gioui.org/io/clipboard"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+)
+
+var (
+	gridColor  = color.NRGBA{R: 0x20, G: 0x60, B: 0xc0, A: 0x30}
+	rulerColor = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xc0}
+	tickColor  = color.NRGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+)
+
+// rulerSize is the thickness of the rulers in pixels.
+const rulerSize = 18
+
+// overlay draws a grid and rulers in turtle coordinates over the drawing,
+// shows the position and the color under the cursor, and copies the
+// position clicked to the clipboard.
+type overlay struct {
+	grid   int         // spacing of the grid lines in pixels
+	cursor image.Point // the pixel under the cursor, in image coordinates
+	hover  bool        // whether the cursor is over the drawing
+	copied string      // the position last copied
+}
+
+// toWorld converts a pixel of the image to turtle coordinates, whose
+// origin is at the bottom left, as in World.setPoint.
+func toWorld(p image.Point, b image.Rectangle) image.Point {
+	return image.Pt(p.X, b.Dy()-p.Y-1)
+}
+
+// layout draws the overlay over img, the drawing shown in the window.
+func (o *overlay) layout(gtx layout.Context, th *material.Theme, img *image.RGBA) {
+	b := img.Bounds()
+	gtx.Constraints.Min = image.Point{}
+	for _, e := range gtx.Events(o) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		o.cursor = image.Pt(int(e.Position.X), int(e.Position.Y))
+		o.hover = e.Type != pointer.Leave && o.cursor.In(b)
+		if e.Type == pointer.Press && o.hover {
+			w := toWorld(o.cursor, b)
+			o.copied = fmt.Sprintf("%d, %d", w.X, w.Y)
+			clipboard.WriteOp{Text: o.copied}.Add(gtx.Ops)
+			log.Printf("copied %s", o.copied)
+		}
+	}
+	// let clicks through to the inspector
+	pass := pointer.PassOp{}.Push(gtx.Ops)
+	area := clip.Rect(b).Push(gtx.Ops)
+	pointer.InputOp{
+		Tag:   o,
+		Types: pointer.Press | pointer.Move | pointer.Enter | pointer.Leave,
+	}.Add(gtx.Ops)
+	area.Pop()
+	pass.Pop()
+
+	o.drawGrid(gtx, th, b)
+
+	if o.hover {
+		w := toWorld(o.cursor, b)
+		c := color.NRGBAModel.Convert(img.At(o.cursor.X, o.cursor.Y)).(color.NRGBA)
+		text := fmt.Sprintf("x %d  y %d  #%02x%02x%02x%02x", w.X, w.Y, c.R, c.G, c.B, c.A)
+		if o.copied != "" {
+			text += "  (copied " + o.copied + ")"
+		}
+		at := op.Offset(f32.Pt(rulerSize+4, 4)).Push(gtx.Ops)
+		paint.FillShape(gtx.Ops, c, clip.Rect(image.Rect(0, 2, 14, 16)).Op())
+		readout := op.Offset(f32.Pt(18, 0)).Push(gtx.Ops)
+		material.Body2(th, text).Layout(gtx)
+		readout.Pop()
+		at.Pop()
+	}
+}
+
+// drawGrid draws the grid lines, and the rulers along the left and bottom
+// edges with the turtle coordinates of the lines.
+func (o *overlay) drawGrid(gtx layout.Context, th *material.Theme, b image.Rectangle) {
+	h := b.Dy()
+	// labels at least 50 pixels apart
+	every := (50 + o.grid - 1) / o.grid * o.grid
+
+	for x := 0; x < b.Dx(); x += o.grid {
+		paint.FillShape(gtx.Ops, gridColor, clip.Rect(image.Rect(x, 0, x+1, h)).Op())
+	}
+	for y := 0; y < h; y += o.grid {
+		iy := h - y - 1
+		paint.FillShape(gtx.Ops, gridColor, clip.Rect(image.Rect(0, iy, b.Dx(), iy+1)).Op())
+	}
+
+	paint.FillShape(gtx.Ops, rulerColor, clip.Rect(image.Rect(0, 0, rulerSize, h)).Op())
+	paint.FillShape(gtx.Ops, rulerColor, clip.Rect(image.Rect(0, h-rulerSize, b.Dx(), h)).Op())
+	label := func(x, y int, s string) {
+		at := op.Offset(f32.Pt(float32(x), float32(y))).Push(gtx.Ops)
+		material.Caption(th, s).Layout(gtx)
+		at.Pop()
+	}
+	for x := 0; x < b.Dx(); x += o.grid {
+		tick := 4
+		if x%every == 0 {
+			tick = 8
+			if x > 0 {
+				label(x+2, h-rulerSize+2, fmt.Sprint(x))
+			}
+		}
+		paint.FillShape(gtx.Ops, tickColor, clip.Rect(image.Rect(x, h-tick, x+1, h)).Op())
+	}
+	for y := 0; y < h; y += o.grid {
+		iy := h - y - 1
+		tick := 4
+		if y%every == 0 {
+			tick = 8
+			if y > 0 {
+				label(1, iy+1, fmt.Sprint(y))
+			}
+		}
+		paint.FillShape(gtx.Ops, tickColor, clip.Rect(image.Rect(0, iy, tick, iy+1)).Op())
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paceMode selects how the drawing animation is timed.
+type paceMode int
+
+const (
+	// paceStep sleeps a fixed time for every turtle step, the original
+	// behaviour: short and long arcs take the same time.
+	paceStep paceMode = iota
+	// paceVelocity sleeps in proportion to the length traced, so the pen
+	// moves at a constant speed in pixels per second.
+	paceVelocity
+	// paceNone draws as fast as possible.
+	paceNone
+)
+
+var paceModeNames = map[string]paceMode{
+	"step":     paceStep,
+	"velocity": paceVelocity,
+	"none":     paceNone,
+}
+
+func (m paceMode) String() string {
+	for name, mode := range paceModeNames {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("paceMode(%d)", int(m))
+}
+
+// pacing describes how fast a part of the mascot is drawn.
+type pacing struct {
+	mode     paceMode
+	speed    int     // steps per second, for paceStep
+	velocity float64 // pixels per second, for paceVelocity
+	travel   bool    // animate pen-up moves as a travelling cursor
+}
+
+// stepDelay returns how long to wait after the pen traced dist pixels.
+func (p pacing) stepDelay(dist float64) time.Duration {
+	switch p.mode {
+	case paceStep:
+		if p.speed <= 0 {
+			return 0
+		}
+		return time.Second / time.Duration(p.speed)
+	case paceVelocity:
+		return p.travelDelay(dist)
+	}
+	return 0
+}
+
+// travelDelay returns how long a pen-up move of dist pixels takes. Travel
+// always uses the velocity, whatever the drawing mode.
+func (p pacing) travelDelay(dist float64) time.Duration {
+	if p.mode == paceNone || p.velocity <= 0 {
+		return 0
+	}
+	if dist < 0 {
+		dist = -dist
+	}
+	return time.Duration(dist / p.velocity * float64(time.Second))
+}
+
+// parsePacing reads a pacing override of the form "mode[:value][:travel]",
+// where value is the speed for step mode, a whole number of steps per
+// second, and the velocity otherwise, and travel or notravel turns the
+// travelling cursor on or off. Fields that are not given keep their value
+// from def.
+func parsePacing(s string, def pacing) (pacing, error) {
+	p := def
+	fields := strings.Split(s, ":")
+	mode, ok := paceModeNames[fields[0]]
+	if !ok {
+		return p, fmt.Errorf("unknown pace mode %q", fields[0])
+	}
+	p.mode = mode
+	for i, f := range fields[1:] {
+		switch {
+		case f == "travel":
+			p.travel = true
+		case f == "notravel":
+			p.travel = false
+		case i == 0 && mode == paceStep:
+			v, err := strconv.Atoi(f)
+			if err != nil || v <= 0 {
+				return p, fmt.Errorf("bad step speed %q, want a positive whole number", f)
+			}
+			p.speed = v
+		case i == 0:
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil || v <= 0 {
+				return p, fmt.Errorf("bad pace value %q", f)
+			}
+			p.velocity = v
+		default:
+			return p, fmt.Errorf("bad pacing %q, want mode[:value][:travel|:notravel]", s)
+		}
+	}
+	return p, nil
+}
+
+// parsePartPacing reads a comma separated list of
+// part=mode[:value][:travel|:notravel] overrides, such as
+// "eyes=velocity:150:travel,rainbowCircle=step". The parts must be among
+// names, unless names is nil.
+func parsePartPacing(s string, def pacing, names []string) (map[string]pacing, error) {
+	m := make(map[string]pacing)
+	if s == "" {
+		return m, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		i := strings.IndexByte(item, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("bad part pacing %q, want part=mode[:value][:travel]", item)
+		}
+		name := item[:i]
+		if names != nil && !contains(names, name) {
+			return nil, fmt.Errorf("unknown part %q", name)
+		}
+		p, err := parsePacing(item[i+1:], def)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = p
+	}
+	return m, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// gradientKind is what a gradient runs along.
+type gradientKind int
+
+const (
+	// gradientAlong runs along the length of the stroke.
+	gradientAlong gradientKind = iota
+	// gradientLinear runs on the canvas, along the line from p0 to p1.
+	gradientLinear
+	// gradientRadial runs on the canvas, from the center p0 out to radius.
+	gradientRadial
+)
+
+// spreadMode is how a gradient continues past its last stop.
+type spreadMode int
+
+const (
+	spreadPad     spreadMode = iota // keep the color of the end stops
+	spreadRepeat                    // start over from the first stop
+	spreadReflect                   // go back and forth
+)
+
+var spreadModeNames = map[string]spreadMode{
+	"pad":     spreadPad,
+	"repeat":  spreadRepeat,
+	"reflect": spreadReflect,
+}
+
+func (m spreadMode) String() string {
+	for name, mode := range spreadModeNames {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("spreadMode(%d)", int(m))
+}
+
+// colorStop is the color of a gradient at offset, from 0 to 1.
+type colorStop struct {
+	offset float64
+	color  color.Color
+}
+
+// gradient is a paint whose color changes across the canvas, or along the
+// stroke it is drawn with. Points are in world coordinates.
+type gradient struct {
+	kind   gradientKind
+	stops  []colorStop
+	hsv    bool // interpolate hue, saturation and value instead of RGB
+	spread spreadMode
+
+	length float64 // gradientAlong: the stroke length the stops span
+	p0, p1 vec     // gradientLinear: start and end; gradientRadial: p0 center
+	radius float64 // gradientRadial
+}
+
+// alongGradient returns a gradient running over the first length pixels
+// of each stroke drawn with it.
+func alongGradient(length float64, stops ...colorStop) *gradient {
+	return newGradient(&gradient{kind: gradientAlong, length: length}, stops)
+}
+
+// linearGradient returns a gradient running from (x0, y0) to (x1, y1).
+func linearGradient(x0, y0, x1, y1 float64, stops ...colorStop) *gradient {
+	return newGradient(&gradient{kind: gradientLinear, p0: vec{x0, y0}, p1: vec{x1, y1}}, stops)
+}
+
+// radialGradient returns a gradient running out from (cx, cy) to radius r.
+func radialGradient(cx, cy, r float64, stops ...colorStop) *gradient {
+	return newGradient(&gradient{kind: gradientRadial, p0: vec{cx, cy}, radius: r}, stops)
+}
+
+func newGradient(g *gradient, stops []colorStop) *gradient {
+	g.stops = append([]colorStop(nil), stops...)
+	sort.SliceStable(g.stops, func(i, j int) bool { return g.stops[i].offset < g.stops[j].offset })
+	return g
+}
+
+// withSpread returns a copy of g continuing past its last stop following
+// mode.
+func (g *gradient) withSpread(mode spreadMode) *gradient {
+	c := *g
+	c.spread = mode
+	return &c
+}
+
+// evenStops spreads colors evenly from offset 0 to 1.
+func evenStops(colors ...color.Color) []colorStop {
+	stops := make([]colorStop, len(colors))
+	for i, c := range colors {
+		stops[i].color = c
+		if len(colors) > 1 {
+			stops[i].offset = float64(i) / float64(len(colors)-1)
+		}
+	}
+	return stops
+}
+
+// offset returns the position in the gradient, before spreading, of the
+// world point p at distance along from the start of its stroke.
+func (g *gradient) offset(p vec, along float64) float64 {
+	switch g.kind {
+	case gradientLinear:
+		d := g.p1.sub(g.p0)
+		l2 := d.dot(d)
+		if l2 == 0 {
+			return 0
+		}
+		return p.sub(g.p0).dot(d) / l2
+	case gradientRadial:
+		if g.radius <= 0 {
+			return 1
+		}
+		return p.sub(g.p0).length() / g.radius
+	}
+	if g.length <= 0 {
+		return 0
+	}
+	return along / g.length
+}
+
+// spreadOffset maps t into [0, 1] following the spread mode.
+func (g *gradient) spreadOffset(t float64) float64 {
+	switch g.spread {
+	case spreadRepeat:
+		return t - math.Floor(t)
+	case spreadReflect:
+		t = math.Mod(math.Abs(t), 2)
+		if t > 1 {
+			t = 2 - t
+		}
+		return t
+	}
+	return math.Max(0, math.Min(1, t))
+}
+
+// colorAt returns the premultiplied color of the gradient at t, before
+// spreading.
+func (g *gradient) colorAt(t float64) premul {
+	if len(g.stops) == 0 {
+		return premul{}
+	}
+	t = g.spreadOffset(t)
+	i := sort.Search(len(g.stops), func(i int) bool { return g.stops[i].offset >= t })
+	switch {
+	case i == 0:
+		return toPremul(g.stops[0].color)
+	case i == len(g.stops):
+		return toPremul(g.stops[len(g.stops)-1].color)
+	}
+	s0, s1 := g.stops[i-1], g.stops[i]
+	f := 0.0
+	if s1.offset > s0.offset {
+		f = (t - s0.offset) / (s1.offset - s0.offset)
+	}
+	return mixColors(s0.color, s1.color, f, g.hsv)
+}
+
+// mixColors interpolates between c0 and c1, straight rather than
+// premultiplied so that transparent stops do not darken the colors, and
+// returns the premultiplied result.
+func mixColors(c0, c1 color.Color, f float64, hsv bool) premul {
+	n0 := color.NRGBAModel.Convert(c0).(color.NRGBA)
+	n1 := color.NRGBAModel.Convert(c1).(color.NRGBA)
+	lerp := func(a, b uint8) float64 {
+		return (float64(a) + (float64(b)-float64(a))*f) / 0xff
+	}
+	alpha := lerp(n0.A, n1.A)
+	var r, g, b float64
+	if hsv {
+		h0, s0, v0 := rgbToHSV(n0)
+		h1, s1, v1 := rgbToHSV(n1)
+		// turn the short way round the color wheel
+		dh := math.Remainder(h1-h0, 360)
+		r, g, b = hsvToRGB(h0+dh*f, s0+(s1-s0)*f, v0+(v1-v0)*f)
+	} else {
+		r, g, b = lerp(n0.R, n1.R), lerp(n0.G, n1.G), lerp(n0.B, n1.B)
+	}
+	return premul{r * alpha, g * alpha, b * alpha, alpha}
+}
+
+// rgbToHSV returns the hue in degrees, saturation and value of c.
+func rgbToHSV(c color.NRGBA) (h, s, v float64) {
+	r, g, b := float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	v = max
+	d := max - min
+	if max > 0 {
+		s = d / max
+	}
+	if d == 0 {
+		return 0, s, v
+	}
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// hsvToRGB returns the red, green and blue, from 0 to 1, of a color given
+// as hue in degrees, saturation and value.
+func hsvToRGB(h, s, v float64) (r, g, b float64) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
+
+// Change the Pen color, replacing any gradient.
+func (a *artist) SetColor(c color.Color) {
+	a.gradient = nil
+	a.Pen.SetColor(c)
+}
+
+// Paint with the gradient g instead of the pen color. A nil g goes back to
+// the pen color.
+func (a *artist) SetGradient(g *gradient) {
+	a.gradient = g
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// playerDrawing is the drawing embedded in the HTML player.
+type playerDrawing struct {
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	Background string       `json:"background"`
+	Strokes    []strokeJSON `json:"strokes"`
+}
+
+// playerRate is how many points the player draws per second at 1x.
+const playerRate = 400
+
+// writePlayer writes a single HTML file replaying the drawing of the
+// records on the layers named in names, or all if nil, on a canvas.
+func writePlayer(path string, s *layerStack, names []string, records []*record) error {
+	d := playerDrawing{
+		Width:      s.bounds.Dx(),
+		Height:     s.bounds.Dy(),
+		Background: cssColor(s.background, 1),
+	}
+	for _, r := range records {
+		if names == nil || contains(names, r.part) {
+			d.Strokes = append(d.Strokes, toStrokeJSON(r, float64(s.bounds.Dy())))
+		}
+	}
+	// json escapes <, > and &, so the data cannot end the script
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, playerHTML, data, playerRate, drawStrokeJS)
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+const playerHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>冰墩墩</title>
+<style>
+body { font-family: sans-serif; }
+#controls { display: flex; gap: 8px; align-items: center; margin-top: 6px; }
+#scrub { flex: 1; }
+</style>
+</head>
+<body>
+<div id="player" style="display: inline-block">
+<canvas id="c"></canvas>
+<div id="controls">
+<button id="play">pause</button>
+<input id="scrub" type="range" min="0" value="0">
+<select id="speed">
+<option value="0.25">0.25x</option>
+<option value="0.5">0.5x</option>
+<option value="1" selected>1x</option>
+<option value="2">2x</option>
+<option value="4">4x</option>
+<option value="8">8x</option>
+</select>
+<span id="time"></span>
+</div>
+</div>
+<script>
+var drawing = %s;
+var rate = %d;
+%s
+var canvas = document.getElementById("c"), ctx = canvas.getContext("2d");
+canvas.width = drawing.width;
+canvas.height = drawing.height;
+// the strokes done so far, drawn once
+var done = document.createElement("canvas");
+done.width = drawing.width;
+done.height = drawing.height;
+var doneCtx = done.getContext("2d");
+
+// every stroke takes as many steps as it has points, text and fills one
+var starts = [], total = 0;
+drawing.strokes.forEach(function (s) {
+  starts.push(total);
+  total += s.k === "path" ? s.xy.length / 2 : 1;
+});
+starts.push(total);
+
+var scrub = document.getElementById("scrub"), play = document.getElementById("play");
+var speed = document.getElementById("speed"), time = document.getElementById("time");
+scrub.max = total;
+
+var pos = 0, next = 0, playing = true, last = null;
+
+function clearDone() {
+  doneCtx.clearRect(0, 0, done.width, done.height);
+  doneCtx.fillStyle = drawing.background;
+  doneCtx.fillRect(0, 0, done.width, done.height);
+  next = 0;
+}
+
+// show draws the drawing up to step p.
+function show(p) {
+  if (p < pos) clearDone();
+  pos = p;
+  while (next < drawing.strokes.length && starts[next + 1] <= p) {
+    drawStroke(doneCtx, drawing.strokes[next]);
+    next++;
+  }
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  ctx.drawImage(done, 0, 0);
+  var s = drawing.strokes[next];
+  if (s && s.k === "path" && p > starts[next]) {
+    drawStroke(ctx, s, Math.floor(p - starts[next]) + 1);
+  }
+  scrub.value = p;
+  time.textContent = Math.floor(p) + " / " + total;
+}
+
+function frame(t) {
+  if (playing && last !== null) {
+    var p = Math.min(total, pos + (t - last) / 1000 * rate * speed.value);
+    show(p);
+    if (p >= total) setPlaying(false);
+  }
+  last = t;
+  requestAnimationFrame(frame);
+}
+
+function setPlaying(on) {
+  playing = on;
+  play.textContent = on ? "pause" : "play";
+}
+
+play.onclick = function () {
+  if (!playing && pos >= total) show(0);
+  setPlaying(!playing);
+};
+scrub.oninput = function () { show(+scrub.value); };
+
+clearDone();
+show(0);
+requestAnimationFrame(frame);
+</script>
+</body>
+</html>
+`
+
+// htmlCmd renders the drawing and writes it as a self-contained HTML
+// player.
+func htmlCmd(args []string) error {
+	fs := flag.NewFlagSet("html", flag.ExitOnError)
+	out := fs.String("out", "bdd-go.html", "output HTML file")
+	only := fs.String("layers", "", "comma separated layers to export, default all")
+	sceneFile := fs.String("scene", "", "scene file drawn instead of the mascot")
+	tolerance := fs.Float64("simplify", 0.25, "simplify strokes, letting them stray this many pixels, for a smaller file")
+	fs.Parse(args)
+
+	var s *layerStack
+	if *sceneFile != "" {
+		sc, err := loadScene(*sceneFile)
+		if err != nil {
+			return err
+		}
+		s = newLayerStack(int(width), int(hight), background, sc.names())
+		if err := drawScene(s, sc, func(string) pacing { return pacing{mode: paceNone} }, nil); err != nil {
+			return err
+		}
+	} else {
+		s = newCanvas()
+		drawParts(s, pacing{mode: paceNone}, nil, nil)
+	}
+	names, err := layerNames(s, *only)
+	if err != nil {
+		return err
+	}
+	records, stats := simplifyRecords(s.rec.list(), simplifyOptions{tolerance: *tolerance})
+	log.Print(stats)
+	return writePlayer(*out, s, names, records)
+}
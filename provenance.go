@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/widget/material"
+)
+
+// caller returns the file:line of the drawing call being run: the call in
+// the code of the part, just under drawParts, or under main for the
+// commands that draw without it.
+func (a *artist) caller() string {
+	if a.origin != "" {
+		return a.origin
+	}
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var last runtime.Frame
+	for {
+		f, more := frames.Next()
+		if name := funcName(f.Function); name == "drawParts" || name == "main" {
+			break
+		}
+		last = f
+		if !more {
+			break
+		}
+	}
+	if last.File == "" {
+		return "?"
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(last.File), last.Line)
+}
+
+// enter marks the start of a drawing call, finding where it is once if the
+// drawing is recorded. The calls it makes, such as the segments of a curve,
+// are part of it. The returned func marks its end.
+func (a *artist) enter() func() {
+	if a.depth == 0 && a.rec != nil {
+		a.source = a.caller()
+	}
+	a.depth++
+	return func() { a.depth-- }
+}
+
+// funcName returns the name of a function without its package.
+func funcName(full string) string {
+	name := full[strings.LastIndexByte(full, '/')+1:]
+	return name[strings.IndexByte(name, '.')+1:]
+}
+
+// provenance returns where the drawing call being run is, and its index
+// among the calls of the part, counting from 1.
+func (a *artist) provenance() (string, int) {
+	src := a.source
+	if a.depth == 0 {
+		src = a.caller()
+	}
+	if src != a.lastSource {
+		a.lastSource = src
+		a.calls++
+	}
+	return src, a.calls
+}
+
+// describe returns a line telling what drew r.
+func (r *record) describe() string {
+	kind := "stroke"
+	switch r.kind {
+	case recordFill:
+		kind = "fill"
+	case recordText:
+		kind = fmt.Sprintf("text %q", r.text)
+	}
+	return fmt.Sprintf("%s call #%d at %s: %s", r.part, r.call, r.source, kind)
+}
+
+// recordAt returns the last record drawn over the pixel at p, in image
+// coordinates, on the visible layers, or nil if there is none.
+func (s *layerStack) recordAt(p image.Point) *record {
+	if !p.In(s.bounds) {
+		return nil
+	}
+	s.mu.Lock()
+	ls := s.ordered()
+	s.mu.Unlock()
+	records := s.rec.list()
+	// the center of the pixel in world coordinates, as World.setPoint places it
+	w := vec{float64(p.X), float64(s.bounds.Dy() - p.Y - 1)}
+	for i := len(ls) - 1; i >= 0; i-- {
+		l := ls[i]
+		if !l.visible || l.opacity == 0 || l.world.Image.RGBAAt(p.X, p.Y).A == 0 {
+			continue
+		}
+		for j := len(records) - 1; j >= 0; j-- {
+			if r := records[j]; r.part == l.name && r.covers(w, p) {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// covers reports whether r drew over the world point w, at the pixel p of
+// the image.
+func (r *record) covers(w vec, p image.Point) bool {
+	switch r.kind {
+	case recordPath:
+		reach := r.style.width/2 + 1
+		if len(r.points) == 1 {
+			return w.sub(r.points[0]).length() <= reach
+		}
+		for i := 1; i < len(r.points); i++ {
+			if segmentDistance(w, r.points[i-1], r.points[i]) <= reach {
+				return true
+			}
+		}
+	case recordFill:
+		return windingNumber(r.points, w) != 0
+	case recordText:
+		return p.In(r.area)
+	}
+	return false
+}
+
+// windingNumber returns how many times the closed polygon goes around p,
+// counterclockwise.
+func windingNumber(points []vec, p vec) int {
+	wn := 0
+	for i := range points {
+		a, b := points[i], points[(i+1)%len(points)]
+		side := b.sub(a).cross(p.sub(a))
+		switch {
+		case a.y <= p.y && b.y > p.y && side > 0:
+			wn++
+		case a.y > p.y && b.y <= p.y && side < 0:
+			wn--
+		}
+	}
+	return wn
+}
+
+// inspector reports which drawing call drew the pixel clicked in the window.
+type inspector struct {
+	found string // what the last click found
+}
+
+// layout handles the clicks on the drawing, of size bounds, and shows what
+// the last one found under it.
+func (in *inspector) layout(gtx layout.Context, th *material.Theme, s *layerStack) {
+	for _, e := range gtx.Events(in) {
+		if e, ok := e.(pointer.Event); ok && e.Type == pointer.Press {
+			p := image.Pt(int(e.Position.X), int(e.Position.Y))
+			if r := s.recordAt(p); r != nil {
+				in.found = r.describe()
+			} else {
+				in.found = fmt.Sprintf("nothing drawn at %d,%d", p.X, p.Y)
+			}
+			log.Print(in.found)
+		}
+	}
+	gtx.Constraints.Min = image.Point{}
+	area := clip.Rect(s.bounds).Push(gtx.Ops)
+	pointer.InputOp{Tag: in, Types: pointer.Press}.Add(gtx.Ops)
+	area.Pop()
+
+	if in.found != "" {
+		label := op.Offset(f32.Pt(8, float32(s.bounds.Dy()-24))).Push(gtx.Ops)
+		material.Body2(th, in.found).Layout(gtx)
+		label.Pop()
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/Pitrified/go-turtle"
+	"golang.org/x/image/vector"
+)
+
+// strokeKey identifies what a stroke is drawn on and with. The artist
+// starts a new stroke whenever it changes.
+type strokeKey struct {
+	world    *turtle.World
+	img      *image.RGBA
+	color    color.Color
+	gradient *gradient
+	width    float64
+	opacity  float64
+	blend    blendMode
+}
+
+// stroke is what the pen drew since it last went down or changed. It is
+// blended into its image as a single shape: the coverage of its pieces is
+// added up, and each pixel is blended once from its color before the
+// stroke, so translucent strokes stay even where segments meet.
+type stroke struct {
+	key   strokeKey
+	style penStyle
+	dst   *image.RGBA
+	src   premul // pen color times opacity
+	z     vector.Rasterizer
+
+	// the rectangle of dst the stroke may touch so far, grown as segments
+	// are added, with dst before the stroke and the coverage of the stroke
+	// for each of its pixels
+	area image.Rectangle
+	base *image.RGBA
+	cov  []float32
+
+	// for strokes painted with a gradient running along them, the
+	// distance along the stroke of each pixel of area
+	along []float32
+	dist  float64 // length of the stroke so far
+
+	rec *record // the stroke as recorded, nil if not recording
+
+	// the end of the path drawn so far
+	open     bool // the pen is on at the end, the next segment joins it
+	dir      vec  // unit direction of the last segment
+	dashIdx  int  // current entry of the dash pattern
+	dashLeft float64
+
+	// the cap at the open end, shown until the path goes on
+	capMask *image.Alpha
+	capRect image.Rectangle
+}
+
+func newStroke(key strokeKey, style penStyle) *stroke {
+	s := &stroke{
+		key:   key,
+		style: style,
+		dst:   key.img,
+		base:  &image.RGBA{},
+		src:   toPremul(key.color).scale(key.opacity),
+	}
+
+	// start the dash pattern at its phase
+	if len(style.dash) > 0 {
+		total := 0.0
+		for _, d := range style.dash {
+			total += d
+		}
+		phase := math.Mod(style.dashPhase, total)
+		if phase < 0 {
+			phase += total
+		}
+		for phase >= style.dash[s.dashIdx] {
+			phase -= style.dash[s.dashIdx]
+			s.dashIdx = (s.dashIdx + 1) % len(style.dash)
+		}
+		s.dashLeft = style.dash[s.dashIdx] - phase
+	}
+	return s
+}
+
+// strokeMargin is the least the area of a stroke grows by on each side,
+// so that a long stroke is not copied again at every segment.
+const strokeMargin = 32
+
+// grow makes the area of the stroke cover r, keeping the coverage and the
+// colors before the stroke of the pixels it already covered.
+func (s *stroke) grow(r image.Rectangle) {
+	b := s.dst.Bounds()
+	r = r.Intersect(b)
+	if r.Empty() || r.In(s.area) {
+		return
+	}
+	old := s.area
+	n := r
+	if !old.Empty() {
+		n = old.Union(r)
+		// grow by half again, so the copies add up to a few times the
+		// final area
+		mx, my := old.Dx()/2, old.Dy()/2
+		if mx < strokeMargin {
+			mx = strokeMargin
+		}
+		if my < strokeMargin {
+			my = strokeMargin
+		}
+		n = image.Rect(n.Min.X-mx, n.Min.Y-my, n.Max.X+mx, n.Max.Y+my).Intersect(b)
+	}
+
+	base := image.NewRGBA(n)
+	draw.Draw(base, n, s.dst, n.Min, draw.Src)
+	draw.Draw(base, old, s.base, old.Min, draw.Src)
+	s.base = base
+	s.cov = regrow(s.cov, old, n)
+	if s.key.gradient != nil && s.key.gradient.kind == gradientAlong {
+		s.along = regrow(s.along, old, n)
+	}
+	s.area = n
+}
+
+// regrow copies v, one value per pixel of old, into a slice with one
+// value per pixel of n, which contains old.
+func regrow(v []float32, old, n image.Rectangle) []float32 {
+	w := make([]float32, n.Dx()*n.Dy())
+	for y := old.Min.Y; y < old.Max.Y; y++ {
+		i := (y - old.Min.Y) * old.Dx()
+		j := (y-n.Min.Y)*n.Dx() + old.Min.X - n.Min.X
+		copy(w[j:j+old.Dx()], v[i:i+old.Dx()])
+	}
+	return w
+}
+
+// index returns the index in the coverage of the pixel (x, y) of area.
+func (s *stroke) index(x, y int) int {
+	return (y-s.area.Min.Y)*s.area.Dx() + x - s.area.Min.X
+}
+
+// currentStroke returns the stroke the pen is drawing, starting a new one
+// if the pen or the image changed.
+func (a *artist) currentStroke() *stroke {
+	key := strokeKey{
+		world:    a.W,
+		img:      a.W.Image,
+		color:    a.Color,
+		gradient: a.gradient,
+		width:    a.style.width,
+		opacity:  a.opacity,
+		blend:    a.blend,
+	}
+	if a.stroke == nil || a.stroke.key != key {
+		a.stroke = newStroke(key, a.style)
+	}
+	return a.stroke
+}
+
+// endStroke makes the next drawing start a new stroke, which blends over
+// what was drawn so far.
+func (a *artist) endStroke() {
+	a.stroke = nil
+}
+
+// toImage converts a point in world coordinates to the image coordinates
+// of the center of its pixel, as World.setPoint places it.
+func (a *artist) toImage(x, y float64) vec {
+	return vec{x + 0.5, float64(a.W.Height) - y - 0.5}
+}
+
+// line draws a line with the pen, between two points in world coordinates.
+func (a *artist) line(x0, y0, x1, y1 float64) {
+	s := a.currentStroke()
+	if a.rec != nil {
+		// each drawing call is a stroke of its own, for its provenance
+		if s.rec == nil || s.rec.source != a.source {
+			s.rec = a.recordPath()
+		}
+		a.rec.lineTo(s.rec, vec{x0, y0}, vec{x1, y1})
+	}
+	s.segment(a.toImage(x0, y0), a.toImage(x1, y1))
+}
+
+// segment adds the line from p0 to p1 to the stroke, splitting it into
+// dashes, with the join to the previous segment and caps at the ends.
+func (s *stroke) segment(p0, p1 vec) {
+	d := p1.sub(p0)
+	length := d.length()
+	if length < 1e-9 {
+		return
+	}
+	u := d.scale(1 / length)
+	h := s.style.width / 2
+
+	var pieces []polygon
+	add := func(p polygon) {
+		if p != nil {
+			pieces = append(pieces, p.oriented())
+		}
+	}
+
+	wasOpen := s.open
+	s.open = false
+	for t := 0.0; t < length; {
+		step := length - t
+		on := true
+		if len(s.style.dash) > 0 {
+			step = math.Min(step, s.dashLeft)
+			on = s.dashIdx%2 == 0
+		}
+		// the dash ends here, rather than going on in the next segment
+		dashEnds := len(s.style.dash) > 0 && s.dashLeft-step <= 1e-9
+		if on {
+			a, b := p0.add(u.scale(t)), p0.add(u.scale(t+step))
+			if t == 0 && wasOpen {
+				add(joinShape(s.style, a, s.dir, u, h))
+			} else {
+				add(capShape(s.style.cap, a, u.scale(-1), h))
+			}
+			add(segmentQuad(a, b, h))
+			if t+step < length || dashEnds {
+				add(capShape(s.style.cap, b, u, h))
+			} else {
+				s.open = true
+			}
+		}
+		t += step
+		if len(s.style.dash) > 0 {
+			s.dashLeft -= step
+			if dashEnds {
+				s.dashIdx = (s.dashIdx + 1) % len(s.style.dash)
+				s.dashLeft = s.style.dash[s.dashIdx]
+			}
+		}
+	}
+	s.dir = u
+	d0 := s.dist
+	s.dist += length
+
+	// the old end cap is covered by the new pieces or no longer an end
+	dirty := s.capRect
+	s.capMask, s.capRect = nil, image.Rectangle{}
+
+	if mask, r := s.rasterize(pieces); mask != nil {
+		s.addMask(mask, r.Min)
+		s.setAlong(mask, r, p0, u, d0, length)
+		dirty = dirty.Union(r)
+	}
+	if s.open {
+		s.capMask, s.capRect = s.rasterize([]polygon{capShape(s.style.cap, p1, u, h)})
+		if s.capMask != nil {
+			s.grow(s.capRect)
+			s.setAlong(s.capMask, s.capRect, p0, u, d0, length)
+		}
+		dirty = dirty.Union(s.capRect)
+	}
+	s.refresh(dirty)
+}
+
+// setAlong records, for the pixels covered by mask placed on r, their
+// distance along the stroke: d0 at p0, going on in direction u for length.
+func (s *stroke) setAlong(mask *image.Alpha, r image.Rectangle, p0, u vec, d0, length float64) {
+	if s.along == nil {
+		return
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if mask.Pix[mask.PixOffset(x-r.Min.X, y-r.Min.Y)] == 0 {
+				continue
+			}
+			t := vec{float64(x) + 0.5, float64(y) + 0.5}.sub(p0).dot(u)
+			t = math.Max(0, math.Min(length, t))
+			s.along[s.index(x, y)] = float32(d0 + t)
+		}
+	}
+}
+
+// srcAt returns the paint of the stroke at pixel (x, y), whose index in
+// the coverage is i.
+func (s *stroke) srcAt(x, y, i int) premul {
+	g := s.key.gradient
+	if g == nil {
+		return s.src
+	}
+	var along float64
+	if s.along != nil {
+		along = float64(s.along[i])
+	}
+	// the world point whose pixel this is, as in World.setPoint
+	p := vec{float64(x), float64(s.dst.Bounds().Dy() - y - 1)}
+	return g.colorAt(g.offset(p, along)).scale(s.key.opacity)
+}
+
+// rasterize renders the polygons into an anti-aliased coverage mask, and
+// returns it with the rectangle of the image it covers. Nil polygons are
+// skipped; the mask is nil if nothing is left on the image.
+func (s *stroke) rasterize(polys []polygon) (*image.Alpha, image.Rectangle) {
+	var r image.Rectangle
+	first := true
+	for _, p := range polys {
+		for _, v := range p {
+			pr := image.Rect(int(math.Floor(v.x)), int(math.Floor(v.y)), int(math.Ceil(v.x))+1, int(math.Ceil(v.y))+1)
+			if first {
+				r, first = pr, false
+			} else {
+				r = r.Union(pr)
+			}
+		}
+	}
+	r = r.Intersect(s.dst.Bounds())
+	if r.Empty() {
+		return nil, image.Rectangle{}
+	}
+
+	s.z.Reset(r.Dx(), r.Dy())
+	s.z.DrawOp = draw.Src
+	o := vec{float64(r.Min.X), float64(r.Min.Y)}
+	for _, p := range polys {
+		if len(p) == 0 {
+			continue
+		}
+		for i, v := range p {
+			v = v.sub(o)
+			if i == 0 {
+				s.z.MoveTo(float32(v.x), float32(v.y))
+			} else {
+				s.z.LineTo(float32(v.x), float32(v.y))
+			}
+		}
+		s.z.ClosePath()
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, r.Dx(), r.Dy()))
+	s.z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask, r
+}
+
+// addMask adds the coverage of mask, placed with its origin at the image
+// point at, to the stroke.
+func (s *stroke) addMask(mask *image.Alpha, at image.Point) {
+	mb := mask.Bounds()
+	s.grow(mb.Add(at))
+	for y := mb.Min.Y; y < mb.Max.Y; y++ {
+		for x := mb.Min.X; x < mb.Max.X; x++ {
+			p := image.Pt(at.X+x, at.Y+y)
+			if !p.In(s.area) {
+				continue
+			}
+			c := mask.Pix[mask.PixOffset(x, y)]
+			if c == 0 {
+				continue
+			}
+			i := s.index(p.X, p.Y)
+			s.cov[i] = float32(math.Min(1, float64(s.cov[i])+float64(c)/0xff))
+		}
+	}
+}
+
+// refresh blends again the pixels of r from their color before the stroke
+// and the coverage of the stroke, open end cap included. Pixels outside
+// the area of the stroke are left alone.
+func (s *stroke) refresh(r image.Rectangle) {
+	r = r.Intersect(s.area)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := s.index(x, y)
+			c := float64(s.cov[i])
+			if p := image.Pt(x, y); s.capMask != nil && p.In(s.capRect) {
+				m := s.capMask.Pix[s.capMask.PixOffset(x-s.capRect.Min.X, y-s.capRect.Min.Y)]
+				c = math.Min(1, c+float64(m)/0xff)
+			}
+			d := s.base.RGBAAt(x, y)
+			if c > 0 {
+				d = blend(s.key.blend, toPremul(d), s.srcAt(x, y, i).scale(c)).rgba()
+			}
+			s.dst.SetRGBA(x, y, d)
+		}
+	}
+}
+
+// fillMask blends the pen color into the image through mask, placed with
+// its origin at the image point at, as a stroke of its own.
+func (a *artist) fillMask(mask *image.Alpha, at image.Point) {
+	a.endStroke()
+	s := a.currentStroke()
+	s.addMask(mask, at)
+	s.refresh(mask.Bounds().Add(at))
+	a.endStroke()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"sync"
+)
+
+// recordKind is what a record holds.
+type recordKind int
+
+const (
+	recordPath recordKind = iota // a stroke, as the points the pen went through
+	recordText                   // text written with Write
+	recordFill                   // a filled shape, as its corners
+)
+
+// record is one drawing operation, as needed to draw it again in another
+// format. Points are in world coordinates.
+type record struct {
+	kind recordKind
+	part string
+
+	// where the drawing call is: file:line in the code or the scene, and
+	// its index among the calls of the part
+	source string
+	call   int
+
+	// the pen, or the fill paint of recordFill
+	color    color.Color
+	gradient *gradient
+	style    penStyle
+	opacity  float64
+	blend    blendMode
+
+	points []vec // recordPath and recordFill
+	curve  bool  // recordPath: points are the knots of a Catmull-Rom spline
+
+	// recordText: text written at points[0]
+	text  string
+	font  textFont
+	align textAlign
+	area  image.Rectangle // the pixels the text covers, in image coordinates
+}
+
+// recording is the list of everything drawn, in order.
+type recording struct {
+	mu      sync.Mutex
+	records []*record
+}
+
+// add appends r to the recording.
+func (rc *recording) add(r *record) {
+	rc.mu.Lock()
+	rc.records = append(rc.records, r)
+	rc.mu.Unlock()
+}
+
+// lineTo adds the line from p0 to p1 to r, a path record of the
+// recording, which others may be listing meanwhile.
+func (rc *recording) lineTo(r *record, p0, p1 vec) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(r.points) == 0 {
+		r.points = append(r.points, p0)
+	}
+	r.points = append(r.points, p1)
+}
+
+// list returns copies of the records so far, which the drawing going on
+// does not change.
+func (rc *recording) list() []*record {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	list := make([]*record, len(rc.records))
+	for i, r := range rc.records {
+		c := *r
+		c.points = append([]vec(nil), r.points...)
+		list[i] = &c
+	}
+	return list
+}
+
+// removePart drops the records of the named part.
+func (rc *recording) removePart(name string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	kept := rc.records[:0]
+	for _, r := range rc.records {
+		if r.part != name {
+			kept = append(kept, r)
+		}
+	}
+	rc.records = kept
+}
+
+// newRecord returns a record of the given kind with the pen of a.
+func (a *artist) newRecord(kind recordKind) *record {
+	src, call := a.provenance()
+	return &record{
+		kind:     kind,
+		part:     a.part,
+		source:   src,
+		call:     call,
+		color:    a.Color,
+		gradient: a.gradient,
+		style:    a.style,
+		opacity:  a.opacity,
+		blend:    a.blend,
+	}
+}
+
+// recordPath starts recording a new stroke.
+func (a *artist) recordPath() *record {
+	r := a.newRecord(recordPath)
+	a.rec.add(r)
+	return r
+}
+
+// recordText records text written at the turtle position, covering area.
+func (a *artist) recordText(s string, f textFont, align textAlign, area image.Rectangle) {
+	if a.rec == nil {
+		return
+	}
+	r := a.newRecord(recordText)
+	r.points = []vec{{a.X, a.Y}}
+	r.text, r.font, r.align, r.area = s, f, align, area
+	a.rec.add(r)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// reference is an image shown with the drawing in the window to trace
+// over, never drawn on the layers nor exported.
+type reference struct {
+	src    image.Image
+	bounds image.Rectangle // of the drawing
+	image  *image.RGBA     // src as placed over the drawing
+	placed vec             // the offset image is placed at
+	scaled float64         // the scale image is placed with
+	above  bool            // drawn over the layers rather than under them
+
+	// controls in the window
+	show       widget.Bool
+	alpha      widget.Float
+	offX, offY widget.Float
+	scale      widget.Float
+}
+
+// maxReferenceScale is the largest scale the window sets.
+const maxReferenceScale = 4
+
+// loadReference loads the image at path and places it over a drawing of
+// bounds b: its bottom left corner at offset, in turtle coordinates, and
+// scaled by scale.
+func loadReference(path string, b image.Rectangle, offset vec, scale, opacity float64, above bool) (*reference, error) {
+	src, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	if scale <= 0 {
+		return nil, fmt.Errorf("reference scale %g is not positive", scale)
+	}
+	r := &reference{src: src, bounds: b, above: above}
+	r.show.Value = true
+	r.alpha.Value = float32(opacity)
+	r.offX.Value, r.offY.Value = float32(offset.x), float32(offset.y)
+	r.scale.Value = float32(scale)
+	r.place()
+	return r, nil
+}
+
+// place places the image again if the offset or scale set in the window
+// changed.
+func (r *reference) place() {
+	offset := vec{float64(r.offX.Value), float64(r.offY.Value)}
+	scale := float64(r.scale.Value)
+	if r.image != nil && offset == r.placed && scale == r.scaled || scale <= 0 {
+		return
+	}
+	r.image = placeImage(r.src, r.bounds, offset, scale)
+	r.placed, r.scaled = offset, scale
+}
+
+// placeImage returns src scaled by scale, with its bottom left corner at
+// offset in turtle coordinates, in an image of bounds b. Pixels are taken
+// from the nearest source pixel.
+func placeImage(src image.Image, b image.Rectangle, offset vec, scale float64) *image.RGBA {
+	sb := src.Bounds()
+	m := image.NewRGBA(b)
+	// the top left corner in image coordinates
+	left := offset.x
+	top := float64(b.Dy()) - offset.y - float64(sb.Dy())*scale
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		sy := sb.Min.Y + int(math.Floor((float64(y)+0.5-top)/scale))
+		if sy < sb.Min.Y || sy >= sb.Max.Y {
+			continue
+		}
+		for x := b.Min.X; x < b.Max.X; x++ {
+			sx := sb.Min.X + int(math.Floor((float64(x)+0.5-left)/scale))
+			if sx < sb.Min.X || sx >= sb.Max.X {
+				continue
+			}
+			m.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return m
+}
+
+// draw blends the reference onto dst with the opacity, offset and scale
+// set in the window.
+func (r *reference) draw(dst draw.Image) {
+	if r.show.Value {
+		r.place()
+		drawLayer(dst, r.image, float64(r.alpha.Value))
+	}
+}
+
+// layout draws a checkbox and sliders for the opacity, offset and scale of
+// the reference.
+func (r *reference) layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	gtx.Constraints.Min = image.Point{}
+	w, h := float32(r.bounds.Dx()), float32(r.bounds.Dy())
+	slider := func(label string, f *widget.Float, min, max float32) []layout.FlexChild {
+		return []layout.FlexChild{
+			layout.Rigid(material.Caption(th, fmt.Sprintf("%s %.4g", label, f.Value)).Layout),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Max.X = gtx.Px(unit.Dp(120))
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				return material.Slider(th, f, min, max).Layout(gtx)
+			}),
+		}
+	}
+	rows := []layout.FlexChild{layout.Rigid(material.CheckBox(th, &r.show, "reference").Layout)}
+	rows = append(rows, slider("opacity", &r.alpha, 0, 1)...)
+	rows = append(rows, slider("x", &r.offX, -w, w)...)
+	rows = append(rows, slider("y", &r.offY, -h, h)...)
+	rows = append(rows, slider("scale", &r.scale, 0.05, maxReferenceScale)...)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
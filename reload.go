@@ -0,0 +1,178 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+)
+
+// reloadInterval is how often the scene file is checked for changes.
+const reloadInterval = 300 * time.Millisecond
+
+var bannerColor = color.NRGBA{R: 0xb0, G: 0x20, B: 0x20, A: 0xe0}
+
+var canvasMu sync.Mutex
+
+// currentCanvas returns the layer stack shown in the window.
+func currentCanvas() *layerStack {
+	canvasMu.Lock()
+	defer canvasMu.Unlock()
+	return canvas
+}
+
+// setCanvas replaces the layer stack shown in the window.
+func setCanvas(s *layerStack) {
+	canvasMu.Lock()
+	canvas = s
+	canvasMu.Unlock()
+}
+
+// sceneWatcher draws a scene file in the window, and draws it again in a
+// new layer stack whenever the file changes.
+type sceneWatcher struct {
+	path          string
+	pace          pacing
+	partPace      string                    // -part-pace, checked against the parts of each version
+	skipUnchanged bool                      // draw the parts that did not change without pacing
+	setup         func(s *layerStack) error // applied to every new layer stack
+	invalidate    func()                    // redraws the window
+
+	mu    sync.Mutex
+	err   error             // why the scene could not be drawn, shown in a banner
+	run   int               // counts the runs started, to stop the previous one
+	drawn map[string]string // the text of the parts last drawn, by name
+}
+
+// watch polls the scene file, drawing it each time it changes. It never
+// returns.
+func (w *sceneWatcher) watch() {
+	var last os.FileInfo
+	for ; ; time.Sleep(reloadInterval) {
+		fi, err := os.Stat(w.path)
+		if err != nil {
+			w.fail(err)
+			last = nil
+			continue
+		}
+		if last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+			continue
+		}
+		last = fi
+		w.reload()
+	}
+}
+
+// reload parses the scene and starts drawing it, stopping the drawing of
+// its previous version. On a parse error the previous drawing stays.
+func (w *sceneWatcher) reload() {
+	sc, err := loadScene(w.path)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	s := newLayerStack(int(width), int(hight), background, sc.names())
+	if err := w.setup(s); err != nil {
+		s.close()
+		w.fail(err)
+		return
+	}
+	partPacing, err := parsePartPacing(w.partPace, w.pace, sc.names())
+	if err != nil {
+		s.close()
+		w.fail(err)
+		return
+	}
+
+	w.mu.Lock()
+	w.err = nil
+	w.run++
+	run := w.run
+	atomic.StoreInt32(&completed, 0)
+	unchanged := map[string]bool{}
+	for _, p := range sc.parts {
+		unchanged[p.name] = w.skipUnchanged && w.drawn[p.name] == p.text()
+	}
+	w.drawn = map[string]string{}
+	for _, p := range sc.parts {
+		w.drawn[p.name] = p.text()
+	}
+	w.mu.Unlock()
+
+	log.Printf("drawing %s", w.path)
+	setCanvas(s)
+	w.invalidate()
+	go func() {
+		paceOf := func(part string) pacing {
+			if unchanged[part] {
+				return pacing{mode: paceNone}
+			}
+			if pp, ok := partPacing[part]; ok {
+				return pp
+			}
+			return w.pace
+		}
+		stop := func() bool {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			return w.run != run
+		}
+		err := drawScene(s, sc, paceOf, stop)
+		// nothing draws on s again, though it may still be shown
+		s.close()
+		if err == errStopped {
+			return
+		}
+		if err != nil {
+			w.fail(err)
+		}
+		// a newer run may have started since the stop check
+		w.mu.Lock()
+		if w.run == run {
+			atomic.StoreInt32(&completed, 1)
+		}
+		w.mu.Unlock()
+		w.invalidate()
+	}()
+}
+
+// fail shows err in the window.
+func (w *sceneWatcher) fail(err error) {
+	w.mu.Lock()
+	changed := w.err == nil || w.err.Error() != err.Error()
+	w.err = err
+	w.mu.Unlock()
+	if changed {
+		log.Print(err)
+		w.invalidate()
+	}
+}
+
+// layout draws a banner with the error of the last run, if any, across
+// the top of the drawing.
+func (w *sceneWatcher) layout(gtx layout.Context, th *material.Theme) {
+	w.mu.Lock()
+	err := w.err
+	w.mu.Unlock()
+	if err == nil {
+		return
+	}
+	gtx.Constraints.Min = image.Point{}
+	paint.FillShape(gtx.Ops, bannerColor, clip.Rect(image.Rect(0, 0, int(width), 28)).Op())
+	at := op.Offset(f32.Pt(8, 4)).Push(gtx.Ops)
+	l := material.Body1(th, err.Error())
+	l.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	l.MaxLines = 1
+	l.Layout(gtx)
+	at.Pop()
+}
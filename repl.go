@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// replPart is the layer the REPL draws on.
+const replPart = "repl"
+
+// animating counts the drawings under way besides the mascot, which keep
+// the window redrawing.
+var animating int32
+
+const replHelp = `turtle commands are those of scene files: fd 50, rt 90, circle 30 180, color red, pu, pd...
+  history     list the commands run
+  !N          run command N of the history again
+  undo        take back the last command
+  clear       erase the drawing and the history
+  save FILE   write the commands run as a scene file
+  help        show this help
+  quit        exit`
+
+// repl reads turtle commands and runs them at once on a layer of the
+// drawing shown in the window.
+type repl struct {
+	s          *layerStack
+	pace       pacing
+	invalidate func() // redraws the window
+
+	runner  *sceneRunner
+	history []sceneCmd // the commands run, in order
+	n       int        // counts the lines read, for the positions of commands
+}
+
+// newREPL creates a REPL drawing on the repl layer of s.
+func newREPL(s *layerStack, pace pacing, invalidate func()) *repl {
+	r := &repl{s: s, pace: pace, invalidate: invalidate}
+	r.reset()
+	return r
+}
+
+// reset starts over with a new turtle on an erased layer.
+func (r *repl) reset() {
+	r.s.clear(replPart)
+	t := newArtist(r.s.layer(replPart).world, r.pace)
+	t.rec = r.s.rec
+	t.part = replPart
+	r.runner = newSceneRunner(t)
+}
+
+// serve reads lines from in until it ends or quit, writing the results
+// and a prompt to out.
+func (r *repl) serve(in io.Reader, out io.Writer) error {
+	fmt.Fprintln(out, `turtle REPL, "help" lists the commands`)
+	lines := bufio.NewScanner(in)
+	for fmt.Fprint(out, "> "); lines.Scan(); fmt.Fprint(out, "> ") {
+		quit, err := r.exec(lines.Text(), out)
+		if err != nil {
+			fmt.Fprintln(out, err)
+		}
+		if quit {
+			return nil
+		}
+	}
+	return lines.Err()
+}
+
+// exec runs a line, reporting whether it asks to quit.
+func (r *repl) exec(line string, out io.Writer) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	switch fields[0] {
+	case "quit", "exit":
+		return true, nil
+	case "help":
+		fmt.Fprintln(out, replHelp)
+		return false, nil
+	case "history":
+		for i, c := range r.history {
+			fmt.Fprintf(out, "%4d  %s\n", i+1, c.line)
+		}
+		return false, nil
+	case "undo":
+		if len(r.history) == 0 {
+			return false, fmt.Errorf("nothing to undo")
+		}
+		r.redraw(r.history[:len(r.history)-1])
+		return false, nil
+	case "clear":
+		r.redraw(nil)
+		return false, nil
+	case "save":
+		if len(fields) != 2 {
+			return false, fmt.Errorf("save takes a file name")
+		}
+		return false, r.save(fields[1])
+	}
+	if strings.HasPrefix(fields[0], "!") {
+		i, err := strconv.Atoi(fields[0][1:])
+		if err != nil || i < 1 || i > len(r.history) {
+			return false, fmt.Errorf("no command %s in the history", fields[0][1:])
+		}
+		line = r.history[i-1].line
+		fmt.Fprintln(out, line)
+	}
+
+	r.n++
+	c, ok, err := parseSceneCmd(fmt.Sprintf("repl:%d", r.n), line)
+	if err != nil || !ok {
+		return false, err
+	}
+	if c.name == "part" {
+		return false, fmt.Errorf("the REPL draws on a single part")
+	}
+	atomic.AddInt32(&animating, 1)
+	err = r.runner.run(c)
+	atomic.AddInt32(&animating, -1)
+	r.invalidate()
+	if err != nil {
+		return false, err
+	}
+	r.history = append(r.history, c)
+	return false, nil
+}
+
+// redraw erases the drawing and runs cmds again at once, as the new
+// history.
+func (r *repl) redraw(cmds []sceneCmd) {
+	r.reset()
+	pace := r.runner.t.pace
+	r.runner.t.pace = pacing{mode: paceNone}
+	var kept []sceneCmd
+	for _, c := range cmds {
+		if err := r.runner.run(c); err == nil {
+			kept = append(kept, c)
+		}
+	}
+	r.runner.t.pace = pace
+	r.history = kept
+	r.invalidate()
+}
+
+// save writes the history as a scene file.
+func (r *repl) save(path string) error {
+	var b strings.Builder
+	b.WriteString("# turtle REPL session\n")
+	for _, c := range r.history {
+		b.WriteString(c.line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bdd-go/turtlerpc"
+)
+
+// rpcPart is the layer the clients of the control socket draw on.
+const rpcPart = "rpc"
+
+const (
+	maxEvents    = 1000             // events kept for clients to fetch
+	maxEventWait = 30 * time.Second // longest wait of Turtle.Events
+)
+
+// turtleService is the Turtle service of the control socket, drawing on
+// the rpc layer of the drawing shown in the window.
+type turtleService struct {
+	s          *layerStack
+	pace       pacing
+	invalidate func() // redraws the window
+
+	draw   sync.Mutex // held while drawing
+	runner *sceneRunner
+	n      int // counts the commands, for their positions
+
+	mu      sync.Mutex
+	events  []turtlerpc.Event
+	seq     int
+	changed chan struct{} // closed when an event is added
+}
+
+// newTurtleService creates the service drawing on the rpc layer of s.
+func newTurtleService(s *layerStack, pace pacing, invalidate func()) *turtleService {
+	ts := &turtleService{s: s, pace: pace, invalidate: invalidate, changed: make(chan struct{})}
+	ts.reset()
+	return ts
+}
+
+// reset erases the rpc layer and starts over with a new turtle.
+func (ts *turtleService) reset() {
+	ts.s.clear(rpcPart)
+	t := newArtist(ts.s.layer(rpcPart).world, ts.pace)
+	t.rec = ts.s.rec
+	t.part = rpcPart
+	ts.runner = newSceneRunner(t)
+}
+
+// event adds an event for the clients.
+func (ts *turtleService) event(kind, line string, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.seq++
+	e := turtlerpc.Event{Seq: ts.seq, Kind: kind, Line: line}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	ts.events = append(ts.events, e)
+	if len(ts.events) > maxEvents {
+		ts.events = ts.events[len(ts.events)-maxEvents:]
+	}
+	close(ts.changed)
+	ts.changed = make(chan struct{})
+}
+
+// Command runs scene command lines in order, stopping at the first that
+// fails.
+func (ts *turtleService) Command(args turtlerpc.CommandArgs, reply *turtlerpc.CommandReply) error {
+	ts.draw.Lock()
+	defer ts.draw.Unlock()
+	atomic.AddInt32(&animating, 1)
+	defer atomic.AddInt32(&animating, -1)
+	defer ts.invalidate()
+
+	for _, line := range args.Lines {
+		ts.n++
+		c, ok, err := parseSceneCmd(fmt.Sprintf("rpc:%d", ts.n), line)
+		if err == nil && c.name == "part" {
+			err = fmt.Errorf("%s: clients draw on a single part", c.pos)
+		}
+		if err != nil {
+			ts.event("error", line, err)
+			return err
+		}
+		if !ok {
+			continue
+		}
+		ts.event("start", c.line, nil)
+		if err := ts.runner.run(c); err != nil {
+			ts.event("error", c.line, err)
+			return err
+		}
+		ts.event("done", c.line, nil)
+		reply.Run++
+	}
+	return nil
+}
+
+// Reset erases what the clients drew and starts over with a new turtle.
+func (ts *turtleService) Reset(args turtlerpc.Empty, reply *turtlerpc.Empty) error {
+	ts.draw.Lock()
+	ts.reset()
+	ts.draw.Unlock()
+	ts.event("reset", "", nil)
+	ts.invalidate()
+	return nil
+}
+
+// Snapshot returns the drawing shown in the window as a PNG.
+func (ts *turtleService) Snapshot(args turtlerpc.Empty, reply *turtlerpc.SnapshotReply) error {
+	var b bytes.Buffer
+	if err := png.Encode(&b, ts.s.composite()); err != nil {
+		return err
+	}
+	reply.PNG = b.Bytes()
+	return nil
+}
+
+// Events returns the events after args.After, waiting for one up to
+// args.WaitMs milliseconds if there are none yet.
+func (ts *turtleService) Events(args turtlerpc.EventsArgs, reply *turtlerpc.EventsReply) error {
+	wait := time.Duration(args.WaitMs) * time.Millisecond
+	if wait > maxEventWait {
+		wait = maxEventWait
+	}
+	timeout := time.After(wait)
+	for {
+		ts.mu.Lock()
+		for _, e := range ts.events {
+			if e.Seq > args.After {
+				reply.Events = append(reply.Events, e)
+			}
+		}
+		changed := ts.changed
+		ts.mu.Unlock()
+		if len(reply.Events) > 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return nil
+		}
+	}
+}
+
+// listenRPC listens on addr, as given to turtlerpc.SplitAddr. TCP is only
+// served on the loopback interface, and a Unix socket left over by a
+// previous run is replaced, unless something still answers on it.
+func listenRPC(addr string) (net.Listener, error) {
+	network, address := turtlerpc.SplitAddr(addr)
+	if network == "unix" {
+		if fi, err := os.Stat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			conn, err := net.DialTimeout(network, address, time.Second)
+			if err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("%s is in use by another control socket", address)
+			}
+			os.Remove(address)
+		}
+		return net.Listen(network, address)
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("the control socket listens on localhost only, not %s", host)
+	}
+	return net.Listen(network, address)
+}
+
+// serveRPC serves the Turtle service on the connections accepted by l,
+// until it is closed.
+func serveRPC(l net.Listener, ts *turtleService) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Turtle", ts); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
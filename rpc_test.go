@@ -0,0 +1,195 @@
+package main
+
+import (
+	"image"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bdd-go/turtlerpc"
+)
+
+// startRPC serves a turtle service drawing without pacing on a local TCP
+// socket, and returns a client of it.
+func startRPC(t *testing.T) *turtlerpc.Client {
+	t.Helper()
+	s := newLayerStack(int(width), int(hight), background, []string{rpcPart})
+	ts := newTurtleService(s, pacing{mode: paceNone}, func() {})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveRPC(l, ts)
+	c, err := turtlerpc.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		l.Close()
+		s.close()
+	})
+	return c
+}
+
+// isRed reports whether the pixel of m at the world point (x, y) is red.
+func isRed(m image.Image, x, y int) bool {
+	r, g, b, _ := m.At(x, int(hight)-y-1).RGBA()
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
+
+func TestRPCCommandAndReset(t *testing.T) {
+	c := startRPC(t)
+	if err := c.Command("pu", "goto 100 100", "pd", "color red", "width 5", "fd 50"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Bounds().Size(), image.Pt(int(width), int(hight)); got != want {
+		t.Fatalf("snapshot size %v, want %v", got, want)
+	}
+	if !isRed(m, 125, 100) {
+		t.Errorf("no red line at (125, 100): %v", m.At(125, int(hight)-101))
+	}
+
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = c.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if isRed(m, 125, 100) {
+		t.Error("the line is still there after Reset")
+	}
+}
+
+func TestRPCCommandErrors(t *testing.T) {
+	c := startRPC(t)
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{"part head"}, "single part"},
+		{[]string{"fd ten"}, "not a number"},
+		{[]string{"fd 10", "jump"}, "unknown command"},
+		{[]string{"pop"}, "pop without push"},
+	}
+	for _, tt := range tests {
+		err := c.Command(tt.lines...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Command(%q) = %v, want an error containing %q", tt.lines, err, tt.want)
+		}
+	}
+	// the connection is still usable
+	if err := c.Command("fd 10"); err != nil {
+		t.Errorf("Command after errors: %v", err)
+	}
+}
+
+func TestRPCEvents(t *testing.T) {
+	c := startRPC(t)
+	if err := c.Command("fd 10", "rt 90"); err != nil {
+		t.Fatal(err)
+	}
+	events, err := c.Events(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Kind+" "+e.Line)
+	}
+	want := []string{"start fd 10", "done fd 10", "start rt 90", "done rt 90"}
+	if strings.Join(kinds, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("events %q, want %q", kinds, want)
+	}
+	last := events[len(events)-1].Seq
+
+	// nothing after the last event: wait, then return none
+	start := time.Now()
+	events, err = c.Events(last, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("events after the last: %v", events)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("Events returned after %v, want it to wait", d)
+	}
+
+	// a waiting call returns with the events of a command run meanwhile
+	done := make(chan []turtlerpc.Event)
+	go func() {
+		events, err := c.Events(last, 10*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- events
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Command("fd 5"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case events := <-done:
+		if len(events) == 0 || events[0].Seq != last+1 || events[0].Kind != "start" {
+			t.Errorf("waited for events %v, want the start of fd 5", events)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Events did not return after a command")
+	}
+}
+
+func TestListenRPC(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", "192.0.2.1:0", "example.com:0"} {
+		if l, err := listenRPC(addr); err == nil {
+			l.Close()
+			t.Errorf("listenRPC(%q) listens, want it refused", addr)
+		}
+	}
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0"} {
+		l, err := listenRPC(addr)
+		if err != nil {
+			t.Errorf("listenRPC(%q): %v", addr, err)
+			continue
+		}
+		l.Close()
+	}
+}
+
+func TestListenRPCUnix(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bdd.sock")
+	addr := "unix:" + path
+
+	l, err := listenRPC(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l2, err := listenRPC(addr); err == nil {
+		l2.Close()
+		t.Error("listenRPC replaced a socket in use")
+	}
+
+	// a socket left over by a run that ended is replaced
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the socket file is gone: %v", err)
+	}
+	l, err = listenRPC(addr)
+	if err != nil {
+		t.Fatalf("listenRPC over a stale socket: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// scene is a drawing written as turtle commands, one per line:
+//
+//	# comment
+//	part head         start the part, drawn on a layer of its own
+//	pu | pd           lift or lower the pen
+//	fd N | bk N       move forward or backward
+//	lt D | rt D       turn left or right, in degrees
+//	seth D            set the heading
+//	goto X Y          move to a position
+//	circle R [D]      draw D degrees, 360 by default, of a circle turning right
+//	cubic X1 Y1 X2 Y2 X Y
+//	quad X1 Y1 X Y    draw Bezier curves to (X, Y)
+//	color C | fillcolor C
+//	                  set the pen or fill color, by name, #rrggbb, #rrggbbaa or rgb(r,g,b)
+//	width W           set the pen width
+//	opacity O         set the pen opacity, from 0 to 1
+//	begin_fill | end_fill
+//	push | pop        save or restore the turtle and its pen
+//	font SIZE [FACE]  set the font of write
+//	write TEXT        write text at the turtle
+//
+// Commands before the first part belong to a part called scene.
+type scene struct {
+	parts []*scenePart
+}
+
+// scenePart is a part of a scene and its commands.
+type scenePart struct {
+	name string
+	cmds []sceneCmd
+}
+
+// sceneCmd is a command of a scene.
+type sceneCmd struct {
+	pos  string   // file:line, for errors and provenance
+	line string   // the command as written
+	name string   // the command name
+	args []string // its arguments
+	nums []float64
+}
+
+// sceneCommand describes a scene command.
+type sceneCommand struct {
+	numeric  bool // the arguments are numbers
+	min, max int  // how many arguments it takes
+	run      func(r *sceneRunner, c sceneCmd) error
+}
+
+// sceneCommands are the commands of scenes, by name.
+var sceneCommands = map[string]sceneCommand{
+	"pu": {run: func(r *sceneRunner, c sceneCmd) error { r.t.PenUp(); return nil }},
+	"pd": {run: func(r *sceneRunner, c sceneCmd) error { r.t.PenDown(); return nil }},
+	"fd": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.Forward(c.nums[0])
+		return nil
+	}},
+	"bk": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.Backward(c.nums[0])
+		return nil
+	}},
+	"lt": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.Left(c.nums[0])
+		return nil
+	}},
+	"rt": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.Right(c.nums[0])
+		return nil
+	}},
+	"seth": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.SetHeading(c.nums[0])
+		return nil
+	}},
+	"goto": {numeric: true, min: 2, max: 2, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.SetPos(c.nums[0], c.nums[1])
+		return nil
+	}},
+	"circle": {numeric: true, min: 1, max: 2, run: func(r *sceneRunner, c sceneCmd) error {
+		extent := 360.0
+		if len(c.nums) > 1 {
+			extent = c.nums[1]
+		}
+		circle(r.t, c.nums[0], extent)
+		return nil
+	}},
+	"cubic": {numeric: true, min: 6, max: 6, run: func(r *sceneRunner, c sceneCmd) error {
+		n := c.nums
+		r.t.CubicTo(n[0], n[1], n[2], n[3], n[4], n[5])
+		return nil
+	}},
+	"quad": {numeric: true, min: 4, max: 4, run: func(r *sceneRunner, c sceneCmd) error {
+		n := c.nums
+		r.t.QuadTo(n[0], n[1], n[2], n[3])
+		return nil
+	}},
+	"color": {min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.SetColor(parseSVGColor(c.args[0]))
+		return nil
+	}},
+	"fillcolor": {min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.SetFillColor(parseSVGColor(c.args[0]))
+		return nil
+	}},
+	"width": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.SetWidth(c.nums[0])
+		return nil
+	}},
+	"opacity": {numeric: true, min: 1, max: 1, run: func(r *sceneRunner, c sceneCmd) error {
+		r.t.SetOpacity(c.nums[0])
+		return nil
+	}},
+	"begin_fill": {run: func(r *sceneRunner, c sceneCmd) error { r.t.BeginFill(); return nil }},
+	"end_fill":   {run: func(r *sceneRunner, c sceneCmd) error { r.t.EndFill(); return nil }},
+	"push":       {run: func(r *sceneRunner, c sceneCmd) error { r.t.Push(); return nil }},
+	"pop": {run: func(r *sceneRunner, c sceneCmd) error {
+		if len(r.t.states) == 0 {
+			return fmt.Errorf("pop without push")
+		}
+		r.t.Pop()
+		return nil
+	}},
+	"font": {min: 1, max: -1, run: func(r *sceneRunner, c sceneCmd) error {
+		size, err := strconv.ParseFloat(c.args[0], 64)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("bad font size %q", c.args[0])
+		}
+		face := r.font.face
+		if len(c.args) > 1 {
+			face = strings.Join(c.args[1:], " ")
+		}
+		if _, err := fontFace(face); err != nil {
+			return err
+		}
+		r.font = textFont{face: face, size: size}
+		return nil
+	}},
+	"write": {min: 1, max: -1, run: func(r *sceneRunner, c sceneCmd) error {
+		return r.t.Write(strings.Join(c.args, " "), r.font, alignLeft)
+	}},
+}
+
+// sceneAliases are other names of scene commands.
+var sceneAliases = map[string]string{
+	"penup":   "pu",
+	"pendown": "pd",
+	"forward": "fd",
+	"back":    "bk",
+	"left":    "lt",
+	"right":   "rt",
+	"heading": "seth",
+	"setpos":  "goto",
+}
+
+// defaultSceneFont is the font of write until a font command.
+var defaultSceneFont = textFont{face: "Go", size: 16}
+
+// parseSceneCmd parses one line of a scene, at pos. It returns false for
+// blank and comment lines.
+func parseSceneCmd(pos, line string) (sceneCmd, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return sceneCmd{}, false, nil
+	}
+	fields := strings.Fields(line)
+	c := sceneCmd{pos: pos, line: line, name: strings.ToLower(fields[0]), args: fields[1:]}
+	if alias, ok := sceneAliases[c.name]; ok {
+		c.name = alias
+	}
+	if c.name == "part" {
+		if len(c.args) != 1 {
+			return c, true, fmt.Errorf("%s: part takes a name", pos)
+		}
+		return c, true, nil
+	}
+	cmd, ok := sceneCommands[c.name]
+	if !ok {
+		return c, true, fmt.Errorf("%s: unknown command %q", pos, fields[0])
+	}
+	if len(c.args) < cmd.min || cmd.max >= 0 && len(c.args) > cmd.max {
+		return c, true, fmt.Errorf("%s: %s takes %s", pos, c.name, argCount(cmd.min, cmd.max))
+	}
+	if cmd.numeric {
+		for _, a := range c.args {
+			v, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return c, true, fmt.Errorf("%s: %s: %q is not a number", pos, c.name, a)
+			}
+			c.nums = append(c.nums, v)
+		}
+	}
+	if c.name == "color" || c.name == "fillcolor" {
+		if !isSceneColor(c.args[0]) {
+			return c, true, fmt.Errorf("%s: unknown color %q", pos, c.args[0])
+		}
+	}
+	return c, true, nil
+}
+
+// argCount describes how many arguments a command takes.
+func argCount(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d arguments", min)
+	case min == max && min == 0:
+		return "no arguments"
+	case min == max:
+		return fmt.Sprintf("%d arguments", min)
+	}
+	return fmt.Sprintf("%d to %d arguments", min, max)
+}
+
+// isSceneColor reports whether parseSVGColor understands s as a color.
+func isSceneColor(s string) bool {
+	s = strings.ToLower(s)
+	if _, ok := svgColorNames[s]; ok {
+		return true
+	}
+	return strings.HasPrefix(s, "#") && (len(s) == 4 || len(s) == 5 || len(s) == 7 || len(s) == 9) ||
+		strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")")
+}
+
+// parseScene reads a scene from r. name is the file name used in the
+// positions of commands.
+func parseScene(name string, r io.Reader) (*scene, error) {
+	sc := &scene{}
+	var part *scenePart
+	lines := bufio.NewScanner(r)
+	for n := 1; lines.Scan(); n++ {
+		c, ok, err := parseSceneCmd(fmt.Sprintf("%s:%d", name, n), lines.Text())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if c.name == "part" {
+			if sc.part(c.args[0]) != nil {
+				return nil, fmt.Errorf("%s: part %s again", c.pos, c.args[0])
+			}
+			part = &scenePart{name: c.args[0]}
+			sc.parts = append(sc.parts, part)
+			continue
+		}
+		if part == nil {
+			part = &scenePart{name: "scene"}
+			sc.parts = append(sc.parts, part)
+		}
+		part.cmds = append(part.cmds, c)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// loadScene reads the scene file at path.
+func loadScene(path string) (*scene, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseScene(filepath.Base(path), f)
+}
+
+// part returns the named part, or nil.
+func (sc *scene) part(name string) *scenePart {
+	for _, p := range sc.parts {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// names returns the names of the parts in order.
+func (sc *scene) names() []string {
+	names := make([]string, len(sc.parts))
+	for i, p := range sc.parts {
+		names[i] = p.name
+	}
+	return names
+}
+
+// text returns the commands of the part as written, to tell whether it
+// changed.
+func (p *scenePart) text() string {
+	var b strings.Builder
+	for _, c := range p.cmds {
+		b.WriteString(c.line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// sceneRunner runs scene commands with an artist.
+type sceneRunner struct {
+	t    *artist
+	font textFont
+}
+
+// newSceneRunner creates a runner drawing with t.
+func newSceneRunner(t *artist) *sceneRunner {
+	return &sceneRunner{t: t, font: defaultSceneFont}
+}
+
+// run runs c, turning the panics of the drawing into errors. The records
+// drawn carry the position of c.
+func (r *sceneRunner) run(c sceneCmd) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s: %v", c.pos, p)
+		}
+	}()
+	r.t.origin = c.pos
+	defer func() { r.t.origin = "" }()
+	if err := sceneCommands[c.name].run(r, c); err != nil {
+		return fmt.Errorf("%s: %v", c.pos, err)
+	}
+	return nil
+}
+
+// drawScene draws every part of sc on the layer of s named after it, with
+// the pacing paceOf returns for it. Once stop returns true it no longer
+// waits, and stops before the next command, returning errStopped.
+func drawScene(s *layerStack, sc *scene, paceOf func(part string) pacing, stop func() bool) error {
+	if len(sc.parts) == 0 {
+		return nil
+	}
+	t := newArtist(s.layer(sc.parts[0].name).world, paceOf(sc.parts[0].name))
+	t.rec = s.rec
+	t.stop = stop
+	r := newSceneRunner(t)
+	for _, p := range sc.parts {
+		t.W = s.layer(p.name).world
+		t.part = p.name
+		t.calls, t.lastSource = 0, ""
+		t.pace = paceOf(p.name)
+		for _, c := range p.cmds {
+			if stop != nil && stop() {
+				return errStopped
+			}
+			if err := r.run(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// errStopped is returned by drawScene when asked to stop.
+var errStopped = errors.New("stopped")
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// renderParams are the query parameters of a rendering.
+type renderParams struct {
+	size       image.Point // zero for the size of the drawing
+	background color.Color
+	parts      string // comma separated layers, all if empty
+	format     string // png, svg or gif
+}
+
+// parseRenderParams reads the parameters of a rendering from a query:
+// size as WxH or W, keeping the proportions; background as a color or
+// none; parts; and format.
+func parseRenderParams(q url.Values) (renderParams, error) {
+	p := renderParams{background: background, parts: q.Get("parts"), format: q.Get("format")}
+	if s := q.Get("size"); s != "" {
+		w, h := s, ""
+		if i := strings.IndexByte(s, 'x'); i >= 0 {
+			w, h = s[:i], s[i+1:]
+		}
+		var err error
+		if p.size.X, err = strconv.Atoi(w); err != nil || p.size.X <= 0 || p.size.X > 4096 {
+			return p, fmt.Errorf("bad size %q", s)
+		}
+		if h == "" {
+			p.size.Y = p.size.X * int(hight) / int(width)
+		} else if p.size.Y, err = strconv.Atoi(h); err != nil || p.size.Y <= 0 || p.size.Y > 4096 {
+			return p, fmt.Errorf("bad size %q", s)
+		}
+	}
+	switch bg := q.Get("background"); {
+	case bg == "none":
+		p.background = color.Transparent
+	case bg != "":
+		if !isSceneColor(bg) {
+			return p, fmt.Errorf("bad background %q", bg)
+		}
+		p.background = parseSVGColor(bg)
+	}
+	switch p.format {
+	case "":
+		p.format = "png"
+	case "png", "svg", "gif":
+	default:
+		return p, fmt.Errorf("unknown format %q", p.format)
+	}
+	return p, nil
+}
+
+// key returns the cache key of the rendering of the scene source with p.
+func (p renderParams) key(source []byte) string {
+	h := sha256.New()
+	r, g, b, a := p.background.RGBA()
+	fmt.Fprintf(h, "%v %d,%d,%d,%d %q %s\n", p.size, r, g, b, a, p.parts, p.format)
+	h.Write(source)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// badRequest is an error of the parameters of a request.
+type badRequest struct {
+	error
+}
+
+// rendering is a cached response.
+type rendering struct {
+	contentType string
+	data        []byte
+}
+
+// renderServer renders the mascot, or a scene file, over HTTP.
+type renderServer struct {
+	scenePath string // the scene file drawn instead of the mascot, if any
+	pace      pacing // the pacing of the live view
+
+	mu       sync.Mutex
+	cache    map[string]*rendering
+	order    []string // the cache keys, oldest first
+	maxCache int
+}
+
+// source reads the scene file, if any, returning it and its text.
+func (rs *renderServer) source() (*scene, []byte, error) {
+	if rs.scenePath == "" {
+		return nil, nil, nil
+	}
+	data, err := os.ReadFile(rs.scenePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc, err := parseScene(filepath.Base(rs.scenePath), bytes.NewReader(data))
+	return sc, data, err
+}
+
+// draw creates a layer stack over bg for sc, or the mascot if sc is nil,
+// and returns it with a function drawing on it, stopping early once stop
+// returns true. The caller closes the stack once drawn.
+func (rs *renderServer) draw(sc *scene, bg color.Color, pace pacing, stop func() bool) (*layerStack, func() error) {
+	if sc == nil {
+		s := newLayerStack(int(width), int(hight), bg, partNames())
+		return s, func() error {
+			return drawParts(s, pace, nil, stop)
+		}
+	}
+	s := newLayerStack(int(width), int(hight), bg, sc.names())
+	return s, func() error {
+		return drawScene(s, sc, func(string) pacing { return pace }, stop)
+	}
+}
+
+// render returns the rendering with p, from the cache if it was made
+// before.
+func (rs *renderServer) render(p renderParams) (*rendering, bool, error) {
+	sc, source, err := rs.source()
+	if err != nil {
+		return nil, false, err
+	}
+	key := p.key(source)
+	rs.mu.Lock()
+	cached := rs.cache[key]
+	rs.mu.Unlock()
+	if cached != nil {
+		return cached, true, nil
+	}
+
+	s, drawAll := rs.draw(sc, p.background, pacing{mode: paceNone}, nil)
+	defer s.close()
+	names, err := layerNames(s, p.parts)
+	if err != nil {
+		return nil, false, badRequest{err}
+	}
+	if err := drawAll(); err != nil {
+		return nil, false, err
+	}
+	r := &rendering{}
+	var b bytes.Buffer
+	switch p.format {
+	case "png":
+		r.contentType = "image/png"
+		err = png.Encode(&b, scaleImage(s.compositeOf(names), p.size))
+	case "svg":
+		r.contentType = "image/svg+xml"
+		records, _ := simplifyRecords(s.rec.list(), simplifyOptions{merge: true})
+		err = writeSVG(&b, s, names, records, p.size)
+	case "gif":
+		r.contentType = "image/gif"
+		err = gif.EncodeAll(&b, partsAnimation(s, names, p.size))
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	r.data = b.Bytes()
+
+	rs.mu.Lock()
+	if rs.cache[key] == nil {
+		rs.cache[key] = r
+		rs.order = append(rs.order, key)
+		for len(rs.order) > rs.maxCache {
+			delete(rs.cache, rs.order[0])
+			rs.order = rs.order[1:]
+		}
+	}
+	rs.mu.Unlock()
+	return r, false, nil
+}
+
+// partsAnimation returns a GIF showing the parts named in names, or the
+// visible ones if nil, appearing one after the other.
+func partsAnimation(s *layerStack, names []string, size image.Point) *gif.GIF {
+	var shown []string
+	s.mu.Lock()
+	for _, l := range s.ordered() {
+		if names == nil && l.visible || contains(names, l.name) {
+			shown = append(shown, l.name)
+		}
+	}
+	s.mu.Unlock()
+
+	anim := &gif.GIF{}
+	for i := range shown {
+		m := scaleImage(s.compositeOf(shown[:i+1]), size)
+		frame := image.NewPaletted(m.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(frame, m.Bounds(), m, image.Point{})
+		delay := 40
+		if i == len(shown)-1 {
+			delay = 300
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return anim
+}
+
+// scaleImage returns m resized to size, each pixel the average of those of
+// m it covers, or m itself if size is zero or its size.
+func scaleImage(m *image.RGBA, size image.Point) *image.RGBA {
+	b := m.Bounds()
+	if size == (image.Point{}) || size == b.Size() {
+		return m
+	}
+	out := image.NewRGBA(image.Rectangle{Max: size})
+	for y := 0; y < size.Y; y++ {
+		y0 := b.Min.Y + y*b.Dy()/size.Y
+		y1 := b.Min.Y + (y+1)*b.Dy()/size.Y
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size.X; x++ {
+			x0 := b.Min.X + x*b.Dx()/size.X
+			x1 := b.Min.X + (x+1)*b.Dx()/size.X
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			// premultiplied, so averaging the channels is right
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := m.RGBAAt(sx, sy)
+					sum[0] += int(c.R)
+					sum[1] += int(c.G)
+					sum[2] += int(c.B)
+					sum[3] += int(c.A)
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			out.SetRGBA(x, y, color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)})
+		}
+	}
+	return out
+}
+
+// handleRender serves /render, the drawing as PNG, SVG or GIF.
+func (rs *renderServer) handleRender(w http.ResponseWriter, req *http.Request) {
+	p, err := parseRenderParams(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r, hit, err := rs.render(p)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, ok := err.(badRequest); ok {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", r.contentType)
+	if hit {
+		w.Header().Set("X-Cache", "hit")
+	} else {
+		w.Header().Set("X-Cache", "miss")
+	}
+	w.Write(r.data)
+}
+
+// handleEvents serves /events, drawing as Server-Sent Events: a start
+// event with the size and background, a stroke event with a strokeJSON as
+// each drawing call is done, and a done event with the number of strokes
+// sent or a failed event with the error.
+func (rs *renderServer) handleEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	q := req.URL.Query()
+	p, err := parseRenderParams(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pace := rs.pace
+	if s := q.Get("pace"); s != "" {
+		if pace, err = parsePacing(s, rs.pace); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	sc, _, err := rs.source()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the drawing stops when the client goes
+	stop := func() bool { return req.Context().Err() != nil }
+	s, drawAll := rs.draw(sc, p.background, pace, stop)
+	names, err := layerNames(s, p.parts)
+	if err != nil {
+		s.close()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(event string, v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	}
+	send("start", map[string]interface{}{
+		"width":      s.bounds.Dx(),
+		"height":     s.bounds.Dy(),
+		"background": cssColor(s.background, 1),
+	})
+	flusher.Flush()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- drawAll()
+		s.close()
+	}()
+	// the records looked at, and the strokes of them sent
+	next, sent := 0, 0
+	// a record is sent once the next one is started, or the drawing done
+	flush := func(all bool) {
+		records := s.rec.list()
+		end := len(records) - 1
+		if all {
+			end = len(records)
+		}
+		for ; next < end; next++ {
+			r := records[next]
+			if names == nil || contains(names, r.part) {
+				send("stroke", toStrokeJSON(r, float64(s.bounds.Dy())))
+				sent++
+			}
+		}
+		flusher.Flush()
+	}
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case err := <-done:
+			flush(true)
+			if err != nil {
+				send("failed", err.Error())
+			} else {
+				send("done", sent)
+			}
+			flusher.Flush()
+			return
+		case <-tick.C:
+			flush(false)
+		}
+	}
+}
+
+// handleIndex serves the live view, drawing the events of /events with
+// the same query on a canvas.
+func (rs *renderServer) handleIndex(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, liveViewHTML, drawStrokeJS)
+}
+
+const liveViewHTML = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>冰墩墩</title></head>
+<body>
+<canvas id="c"></canvas>
+<p id="status">connecting</p>
+<script>
+%s
+var canvas = document.getElementById("c"), ctx = canvas.getContext("2d");
+var status = document.getElementById("status"), strokes = 0;
+var events = new EventSource("events" + location.search);
+events.addEventListener("start", function (e) {
+  var d = JSON.parse(e.data);
+  canvas.width = d.width;
+  canvas.height = d.height;
+  ctx.fillStyle = d.background;
+  ctx.fillRect(0, 0, d.width, d.height);
+  status.textContent = "drawing";
+});
+events.addEventListener("stroke", function (e) {
+  drawStroke(ctx, JSON.parse(e.data));
+  status.textContent = "drawing, " + (++strokes) + " strokes";
+});
+events.addEventListener("done", function () {
+  status.textContent = "done, " + strokes + " strokes";
+  events.close();
+});
+events.addEventListener("failed", function (e) {
+  status.textContent = JSON.parse(e.data);
+  events.close();
+});
+// the connection went: reconnecting would draw it all again
+events.onerror = function () {
+  status.textContent = "disconnected, " + strokes + " strokes";
+  events.close();
+};
+</script>
+</body>
+</html>
+`
+
+// serveCmd serves renderings of the drawing over HTTP.
+func serveCmd(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	sceneFile := fs.String("scene", "", "scene file drawn instead of the mascot, read again for every request")
+	paceFlag := fs.String("pace", "step", "timing of the live view: step, velocity or none")
+	maxCache := fs.Int("cache", 64, "number of renderings kept")
+	fs.Parse(args)
+
+	pace, err := parsePacing(*paceFlag, pacing{speed: speed, velocity: 400})
+	if err != nil {
+		return err
+	}
+	rs := newRenderServer(*sceneFile, pace, *maxCache)
+	log.Printf("serving on http://%s/", *addr)
+	return http.ListenAndServe(*addr, rs.handler())
+}
+
+// newRenderServer creates a server drawing the scene file at path, or the
+// mascot if path is empty.
+func newRenderServer(path string, pace pacing, maxCache int) *renderServer {
+	return &renderServer{
+		scenePath: path,
+		pace:      pace,
+		cache:     map[string]*rendering{},
+		maxCache:  maxCache,
+	}
+}
+
+// handler returns the HTTP handler of the server.
+func (rs *renderServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", rs.handleIndex)
+	mux.HandleFunc("/render", rs.handleRender)
+	mux.HandleFunc("/events", rs.handleEvents)
+	return mux
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// get serves a GET of target with the handler of rs.
+func get(rs *renderServer, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	rs.handler().ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+// readEvents returns the names and data of the Server-Sent Events in body.
+func readEvents(body string) (events, data []string) {
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	return events, data
+}
+
+func TestRenderFormats(t *testing.T) {
+	rs := newRenderServer("", pacing{mode: paceNone}, 8)
+	for _, tt := range []struct{ format, contentType string }{
+		{"png", "image/png"},
+		{"svg", "image/svg+xml"},
+		{"gif", "image/gif"},
+	} {
+		target := "/render?size=150&parts=body,eyes&format=" + tt.format
+		w := get(rs, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: Content-Type %q, want %q", target, got, tt.contentType)
+		}
+		if got := w.Header().Get("X-Cache"); got != "miss" {
+			t.Errorf("%s: X-Cache %q, want miss", target, got)
+		}
+		first := w.Body.String()
+
+		w = get(rs, target)
+		if got := w.Header().Get("X-Cache"); got != "hit" {
+			t.Errorf("%s again: X-Cache %q, want hit", target, got)
+		}
+		if w.Body.String() != first {
+			t.Errorf("%s again: the body changed", target)
+		}
+	}
+}
+
+func TestRenderBadRequest(t *testing.T) {
+	rs := newRenderServer("", pacing{mode: paceNone}, 8)
+	for _, query := range []string{
+		"size=0x100",
+		"size=wide",
+		"size=100x99999",
+		"format=bmp",
+		"background=blurple",
+		"background=%23ff00000",
+		"parts=body,tail",
+	} {
+		if w := get(rs, "/render?"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	rs := newRenderServer("", pacing{mode: paceNone}, 8)
+	w := get(rs, "/events?parts=eyes")
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type %q, want text/event-stream", got)
+	}
+	events, data := readEvents(w.Body.String())
+	if len(events) < 3 || events[0] != "start" || events[len(events)-1] != "done" {
+		t.Fatalf("events %q, want start, strokes and done", events)
+	}
+	for _, e := range events[1 : len(events)-1] {
+		if e != "stroke" {
+			t.Fatalf("events %q, want only strokes between start and done", events)
+		}
+	}
+	if got, want := data[len(data)-1], strconv.Itoa(len(events)-2); got != want {
+		t.Errorf("done with %s strokes, want the %s sent", got, want)
+	}
+	if !strings.Contains(w.Body.String(), `"p":"eyes"`) {
+		t.Error("no stroke of the eyes")
+	}
+	if strings.Contains(w.Body.String(), `"p":"body"`) {
+		t.Error("a stroke of a part not asked for")
+	}
+}
+
+func TestEventsFailed(t *testing.T) {
+	f, err := os.CreateTemp("", "*.scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("part face\nfd 10\npop\n")
+	f.Close()
+
+	rs := newRenderServer(f.Name(), pacing{mode: paceNone}, 8)
+	events, data := readEvents(get(rs, "/events").Body.String())
+	if len(events) == 0 || events[len(events)-1] != "failed" {
+		t.Fatalf("events %q, want them to end with failed", events)
+	}
+	if got := data[len(data)-1]; !strings.Contains(got, "pop without push") {
+		t.Errorf("failed with %s, want the error of the scene", got)
+	}
+}
+
+func TestEventsStop(t *testing.T) {
+	rs := newRenderServer("", pacing{mode: paceStep, speed: 1}, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		rs.handler().ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the events go on after the client went")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import "fmt"
+
+// douglasPeucker simplifies a polyline, keeping the points that stray more
+// than tolerance from the chords of the simplified line. The ends are
+// always kept.
+func douglasPeucker(points []vec, tolerance float64) []vec {
+	if len(points) < 3 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	// ranges still to simplify, as pairs of indices of kept points
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		a, b := points[r[0]], points[r[1]]
+		worst, far := -1, tolerance
+		for i := r[0] + 1; i < r[1]; i++ {
+			if d := segmentDistance(points[i], a, b); d > far {
+				worst, far = i, d
+			}
+		}
+		if worst >= 0 {
+			keep[worst] = true
+			stack = append(stack, [2]int{r[0], worst}, [2]int{worst, r[1]})
+		}
+	}
+	var out []vec
+	for i, p := range points {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// segmentDistance returns the distance from p to the segment from a to b.
+func segmentDistance(p, a, b vec) float64 {
+	d := b.sub(a)
+	l2 := d.dot(d)
+	if l2 == 0 {
+		return p.sub(a).length()
+	}
+	t := p.sub(a).dot(d) / l2
+	switch {
+	case t < 0:
+		t = 0
+	case t > 1:
+		t = 1
+	}
+	return p.sub(a.add(d.scale(t))).length()
+}
+
+// simplifyOptions is the post-processing applied to recorded strokes
+// before they are exported.
+type simplifyOptions struct {
+	merge     bool    // join strokes drawn one after the other with the same pen
+	tolerance float64 // Douglas-Peucker tolerance in pixels, 0 to keep every point
+	smooth    bool    // export the points as the knots of Catmull-Rom curves
+}
+
+// simplifyStats counts the segments of the strokes before and after
+// simplification.
+type simplifyStats struct {
+	strokesBefore, strokesAfter   int
+	segmentsBefore, segmentsAfter int
+}
+
+func (s simplifyStats) String() string {
+	return fmt.Sprintf("%d strokes, %d segments -> %d strokes, %d segments",
+		s.strokesBefore, s.segmentsBefore, s.strokesAfter, s.segmentsAfter)
+}
+
+// simplifyRecords returns records with their strokes simplified following
+// opt. The records given are left as they are.
+func simplifyRecords(records []*record, opt simplifyOptions) ([]*record, simplifyStats) {
+	var st simplifyStats
+	var out []*record
+	for _, r := range records {
+		if r.kind != recordPath {
+			out = append(out, r)
+			continue
+		}
+		st.strokesBefore++
+		st.segmentsBefore += len(r.points) - 1
+		if opt.merge && len(out) > 0 {
+			if last := out[len(out)-1]; last.continuedBy(r) {
+				last.points = append(last.points, r.points[1:]...)
+				continue
+			}
+		}
+		c := *r
+		c.points = append([]vec(nil), r.points...)
+		out = append(out, &c)
+	}
+	for _, r := range out {
+		if r.kind != recordPath {
+			continue
+		}
+		if opt.tolerance > 0 {
+			r.points = douglasPeucker(r.points, opt.tolerance)
+		}
+		// a gradient along the stroke is exported segment by segment
+		r.curve = opt.smooth && (r.gradient == nil || r.gradient.kind != gradientAlong)
+		st.strokesAfter++
+		st.segmentsAfter += len(r.points) - 1
+	}
+	return out, st
+}
+
+// continuedBy reports whether the stroke n starts where r ends and is drawn
+// with the same pen, so that the two can be one.
+func (r *record) continuedBy(n *record) bool {
+	if r.kind != recordPath || n.kind != recordPath || r.part != n.part ||
+		len(r.points) == 0 || len(n.points) == 0 {
+		return false
+	}
+	if r.points[len(r.points)-1].sub(n.points[0]).length() > 1e-6 {
+		return false
+	}
+	if r.color != n.color || r.gradient != n.gradient || r.opacity != n.opacity || r.blend != n.blend {
+		return false
+	}
+	a, b := r.style, n.style
+	if a.width != b.width || a.cap != b.cap || a.join != b.join || a.miterLimit != b.miterLimit ||
+		a.dashPhase != b.dashPhase || len(a.dash) != len(b.dash) {
+		return false
+	}
+	for i := range a.dash {
+		if a.dash[i] != b.dash[i] {
+			return false
+		}
+	}
+	// the dash pattern starts over with each stroke
+	return len(a.dash) == 0
+}
+
+// catmullRom returns the cubic Bezier control points of the Catmull-Rom
+// spline through points, two per segment. A spline whose ends meet is
+// closed smoothly.
+func catmullRom(points []vec) [][2]vec {
+	n := len(points)
+	closed := n > 3 && points[0].sub(points[n-1]).length() < 1e-6
+	at := func(i int) vec {
+		switch {
+		case closed && i < 0:
+			return points[n-2]
+		case closed && i >= n:
+			return points[1]
+		case i < 0:
+			return points[0]
+		case i >= n:
+			return points[n-1]
+		}
+		return points[i]
+	}
+	ctrl := make([][2]vec, n-1)
+	for i := range ctrl {
+		p0, p1, p2, p3 := at(i-1), at(i), at(i+1), at(i+2)
+		ctrl[i] = [2]vec{
+			p1.add(p2.sub(p0).scale(1.0 / 6)),
+			p2.sub(p3.sub(p1).scale(1.0 / 6)),
+		}
+	}
+	return ctrl
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/Pitrified/go-turtle"
+)
+
+// turtleState is what Push saves: where the turtle is and the pen it
+// holds.
+type turtleState struct {
+	turtle turtle.Turtle
+	pen    turtle.Pen
+
+	style        penStyle
+	gradient     *gradient
+	opacity      float64
+	blend        blendMode
+	fillColor    color.Color
+	fillGradient *gradient
+}
+
+// Save the position, heading and pen of the turtle, to be restored by Pop.
+func (a *artist) Push() {
+	a.states = append(a.states, turtleState{
+		turtle:       a.Turtle,
+		pen:          a.Pen,
+		style:        a.style,
+		gradient:     a.gradient,
+		opacity:      a.opacity,
+		blend:        a.blend,
+		fillColor:    a.fillColor,
+		fillGradient: a.fillGradient,
+	})
+}
+
+// Restore the state saved by the last Push. The turtle jumps back without
+// drawing, even if the pen is down. Pop without a matching Push does
+// nothing.
+func (a *artist) Pop() {
+	if len(a.states) == 0 {
+		return
+	}
+	st := a.states[len(a.states)-1]
+	a.states = a.states[:len(a.states)-1]
+
+	a.endStroke()
+	a.Turtle = st.turtle
+	a.Pen = st.pen
+	a.style = st.style
+	a.gradient = st.gradient
+	a.opacity = st.opacity
+	a.blend = st.blend
+	a.fillColor = st.fillColor
+	a.fillGradient = st.fillGradient
+	a.trace()
+}
+
+// Run f, then put the turtle and pen back as they were before it.
+func (a *artist) With(f func()) {
+	a.Push()
+	defer a.Pop()
+	f()
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// lineCap is the shape of the ends of a stroke, and of each dash.
+type lineCap int
+
+const (
+	capRound  lineCap = iota // a half disc around the end
+	capButt                  // square, ending exactly at the end point
+	capSquare                // square, extending half the width past the end
+)
+
+var lineCapNames = map[string]lineCap{
+	"round":  capRound,
+	"butt":   capButt,
+	"square": capSquare,
+}
+
+// lineJoin is the shape of the corner between two consecutive segments.
+type lineJoin int
+
+const (
+	joinRound lineJoin = iota // a disc sector around the corner
+	joinMiter                 // the outer edges extended until they meet
+	joinBevel                 // the outer edges joined by a straight line
+)
+
+var lineJoinNames = map[string]lineJoin{
+	"round": joinRound,
+	"miter": joinMiter,
+	"bevel": joinBevel,
+}
+
+func (c lineCap) String() string {
+	for name, v := range lineCapNames {
+		if v == c {
+			return name
+		}
+	}
+	return fmt.Sprintf("lineCap(%d)", int(c))
+}
+
+func (j lineJoin) String() string {
+	for name, v := range lineJoinNames {
+		if v == j {
+			return name
+		}
+	}
+	return fmt.Sprintf("lineJoin(%d)", int(j))
+}
+
+// penStyle is the shape of the lines drawn by the pen.
+type penStyle struct {
+	width      float64 // in pixels
+	cap        lineCap
+	join       lineJoin
+	miterLimit float64   // longest miter, in widths, before it is beveled
+	dash       []float64 // lengths of the dashes and the gaps between them
+	dashPhase  float64   // how far into the pattern strokes start
+}
+
+// defaultPenStyle is the style of a new artist: round ends and corners,
+// which suit the arcs of the turtle.
+var defaultPenStyle = penStyle{
+	width:      3,
+	cap:        capRound,
+	join:       joinRound,
+	miterLimit: 4,
+}
+
+// Change the Pen size. The line width follows it.
+func (a *artist) SetSize(s int) {
+	a.Pen.SetSize(s)
+	a.style.width = float64(s)
+}
+
+// Change the line width, in pixels.
+func (a *artist) SetWidth(w float64) {
+	a.style.width = w
+	a.Size = int(math.Round(w))
+}
+
+// Change the shape of the ends of lines and dashes.
+func (a *artist) SetCap(c lineCap) {
+	a.endStroke()
+	a.style.cap = c
+}
+
+// Change the shape of the corners between segments.
+func (a *artist) SetJoin(j lineJoin) {
+	a.endStroke()
+	a.style.join = j
+}
+
+// Change the longest miter, as a multiple of the line width, before the
+// corner is beveled instead.
+func (a *artist) SetMiterLimit(l float64) {
+	a.endStroke()
+	a.style.miterLimit = l
+}
+
+// Draw dashed lines: pattern alternates the lengths of dashes and gaps, and
+// phase is how far into the pattern each stroke starts. The pattern runs on
+// over the segments of a stroke, so the dashes of an arc are even. A nil
+// pattern draws solid lines.
+func (a *artist) SetDash(pattern []float64, phase float64) {
+	a.endStroke()
+	total := 0.0
+	for _, d := range pattern {
+		total += d
+	}
+	if total <= 0 {
+		pattern = nil
+	}
+	a.style.dash = append([]float64(nil), pattern...)
+	a.style.dashPhase = phase
+}
+
+// vec is a point or a direction in image coordinates.
+type vec struct {
+	x, y float64
+}
+
+func (v vec) add(w vec) vec       { return vec{v.x + w.x, v.y + w.y} }
+func (v vec) sub(w vec) vec       { return vec{v.x - w.x, v.y - w.y} }
+func (v vec) scale(k float64) vec { return vec{v.x * k, v.y * k} }
+func (v vec) dot(w vec) float64   { return v.x*w.x + v.y*w.y }
+func (v vec) cross(w vec) float64 { return v.x*w.y - v.y*w.x }
+func (v vec) length() float64     { return math.Hypot(v.x, v.y) }
+func (v vec) normal() vec         { return vec{-v.y, v.x} }
+func (v vec) angle() float64      { return math.Atan2(v.y, v.x) }
+func polar(r, angle float64) vec  { return vec{r * math.Cos(angle), r * math.Sin(angle)} }
+
+// polygon is a closed outline. The stroker emits all of them with the same
+// orientation, so that overlapping pieces add up instead of cancelling.
+type polygon []vec
+
+// oriented returns p, reversed if needed to wind clockwise on screen.
+func (p polygon) oriented() polygon {
+	area := 0.0
+	for i := range p {
+		area += p[i].cross(p[(i+1)%len(p)])
+	}
+	if area >= 0 {
+		return p
+	}
+	q := make(polygon, len(p))
+	for i := range p {
+		q[i] = p[len(p)-1-i]
+	}
+	return q
+}
+
+// arcSteps is the number of chords used for an arc of the given radius and
+// sweep, fine enough that the chords are not visible.
+func arcSteps(radius, sweep float64) int {
+	n := int(math.Ceil(math.Abs(sweep) * math.Sqrt(math.Max(radius, 1)) * 2))
+	if n < 2 {
+		n = 2
+	}
+	return n
+}
+
+// sector returns the pie slice of a disc centered on c, from angle a0
+// sweeping by sweep radians.
+func sector(c vec, radius, a0, sweep float64) polygon {
+	n := arcSteps(radius, sweep)
+	p := polygon{c}
+	for i := 0; i <= n; i++ {
+		p = append(p, c.add(polar(radius, a0+sweep*float64(i)/float64(n))))
+	}
+	return p
+}
+
+// segmentQuad returns the body of a line of half width h from a to b.
+func segmentQuad(a, b vec, h float64) polygon {
+	n := b.sub(a).scale(1 / b.sub(a).length()).normal().scale(h)
+	return polygon{a.add(n), b.add(n), b.sub(n), a.sub(n)}
+}
+
+// capShape returns the cap at the end p of a line of half width h going in
+// the unit direction u, or nil for butt caps.
+func capShape(c lineCap, p, u vec, h float64) polygon {
+	n := u.normal().scale(h)
+	switch c {
+	case capSquare:
+		e := u.scale(h)
+		return polygon{p.add(n), p.add(n).add(e), p.sub(n).add(e), p.sub(n)}
+	case capRound:
+		return sector(p, h, n.angle(), -math.Pi)
+	}
+	return nil
+}
+
+// joinShape returns the shape filling the outer side of the corner at p
+// between a line going in the unit direction u0 and one going in u1, or nil
+// when they are aligned.
+func joinShape(st penStyle, p, u0, u1 vec, h float64) polygon {
+	turn := u0.cross(u1)
+	if math.Abs(turn) < 1e-9 && u0.dot(u1) > 0 {
+		return nil
+	}
+	// the outer side is away from the turn
+	side := 1.0
+	if turn > 0 {
+		side = -1
+	}
+	n0, n1 := u0.normal().scale(side), u1.normal().scale(side)
+	o0, o1 := p.add(n0.scale(h)), p.add(n1.scale(h))
+
+	switch st.join {
+	case joinRound:
+		a0 := n0.angle()
+		sweep := math.Remainder(n1.angle()-a0, 2*math.Pi)
+		return sector(p, h, a0, sweep)
+	case joinMiter:
+		m := n0.add(n1)
+		if l := m.length(); l > 1e-9 {
+			m = m.scale(1 / l)
+			// the miter is h/cos of half the angle between the normals
+			cos := m.dot(n0)
+			if cos > 0 && 1/cos <= st.miterLimit {
+				return polygon{p, o0, p.add(m.scale(h / cos)), o1}
+			}
+		}
+	}
+	return polygon{p, o0, o1}
+}
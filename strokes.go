@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// strokeJSON is a record as sent to browsers, which draw it on a canvas
+// with drawStrokeJS. Points are in image coordinates. The keys are short
+// since a drawing has thousands of points.
+type strokeJSON struct {
+	Part   string        `json:"p"`
+	Kind   string        `json:"k"` // path, fill or text
+	Color  string        `json:"c"` // CSS color
+	Colors []string      `json:"cs,omitempty"`
+	Grad   *gradientJSON `json:"g,omitempty"`
+	Width  float64       `json:"w,omitempty"`
+	Cap    string        `json:"cap,omitempty"`
+	Join   string        `json:"join,omitempty"`
+	Miter  float64       `json:"ml,omitempty"`
+	Dash   []float64     `json:"d,omitempty"`
+	Points []float64     `json:"xy"` // x0, y0, x1, y1...
+	Text   string        `json:"t,omitempty"`
+	Font   string        `json:"f,omitempty"` // CSS font
+	Align  string        `json:"a,omitempty"`
+	Blend  string        `json:"b,omitempty"` // canvas composite operation
+	Source string        `json:"s,omitempty"`
+}
+
+// gradientJSON is a linear or radial gradient as a canvas makes them, with
+// stops sampled from the gradient.
+type gradientJSON struct {
+	Radial bool       `json:"r,omitempty"`
+	Coords []float64  `json:"xy"` // x0, y0, x1, y1 or x, y, radius
+	Stops  [][]string `json:"s"`  // offset, CSS color
+}
+
+// gradientSamples is the number of stops of a gradient sent to browsers.
+const gradientSamples = 11
+
+// toStrokeJSON converts r, drawn on an image of the given height. A
+// gradient along the stroke is sent as the color at each point.
+func toStrokeJSON(r *record, height float64) strokeJSON {
+	pt := func(p vec) vec { return vec{p.x + 0.5, height - p.y - 0.5} }
+	s := strokeJSON{Part: r.part, Blend: canvasBlend(r.blend), Source: r.source}
+	for _, p := range r.points {
+		p = pt(p)
+		s.Points = append(s.Points, round1(p.x), round1(p.y))
+	}
+	if r.color != nil {
+		s.Color = cssColor(r.color, r.opacity)
+	}
+
+	switch r.kind {
+	case recordPath:
+		s.Kind = "path"
+		st := r.style
+		s.Width = st.width
+		if st.cap != capRound {
+			s.Cap = st.cap.String()
+		}
+		if st.join != joinRound {
+			s.Join = st.join.String()
+		}
+		if st.join == joinMiter {
+			s.Miter = st.miterLimit
+		}
+		s.Dash = st.dash
+	case recordFill:
+		s.Kind = "fill"
+	case recordText:
+		s.Kind = "text"
+		s.Text = r.text
+		s.Font = cssFont(r.font)
+		s.Align = map[textAlign]string{alignLeft: "", alignCenter: "center", alignRight: "right"}[r.align]
+	}
+
+	g := r.gradient
+	switch {
+	case g == nil:
+	case g.kind == gradientAlong:
+		along := 0.0
+		for i, p := range r.points {
+			if i > 0 {
+				along += p.sub(r.points[i-1]).length()
+			}
+			s.Colors = append(s.Colors, cssColor(g.colorAt(g.offset(p, along)).rgba(), r.opacity))
+		}
+	default:
+		gj := &gradientJSON{Radial: g.kind == gradientRadial}
+		p0, p1 := pt(g.p0), pt(g.p1)
+		if gj.Radial {
+			gj.Coords = []float64{round1(p0.x), round1(p0.y), round1(g.radius)}
+		} else {
+			gj.Coords = []float64{round1(p0.x), round1(p0.y), round1(p1.x), round1(p1.y)}
+		}
+		for i := 0; i < gradientSamples; i++ {
+			t := float64(i) / (gradientSamples - 1)
+			gj.Stops = append(gj.Stops, []string{num(t), cssColor(g.colorAt(t).rgba(), r.opacity)})
+		}
+		s.Grad = gj
+	}
+	return s
+}
+
+// canvasBlend returns the canvas composite operation matching m, or ""
+// for source-over. On a single canvas erasing removes the layers below
+// and the background too.
+func canvasBlend(m blendMode) string {
+	switch m {
+	case blendMultiply:
+		return "multiply"
+	case blendScreen:
+		return "screen"
+	case blendAdd:
+		return "lighter"
+	case blendErase:
+		return "destination-out"
+	}
+	return ""
+}
+
+// cssColor returns c with its alpha scaled by opacity, as a CSS color.
+func cssColor(c color.Color, opacity float64) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	a := float64(n.A) / 0xff * opacity
+	if a >= 1 {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%s)", n.R, n.G, n.B, num(a))
+}
+
+// cssFont returns the CSS font of f.
+func cssFont(f textFont) string {
+	var style string
+	if strings.Contains(f.face, "Italic") {
+		style += "italic "
+	}
+	if strings.Contains(f.face, "Bold") {
+		style += "bold "
+	}
+	family := "sans-serif"
+	if strings.Contains(f.face, "Mono") {
+		family = "monospace"
+	}
+	return fmt.Sprintf("%s%spx %q, %s", style, num(f.size), f.face, family)
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// drawStrokeJS is the JavaScript function drawing a strokeJSON on a canvas
+// context, up to its point n, or whole if n is undefined.
+const drawStrokeJS = `function drawStroke(ctx, s, n) {
+  var xy = s.xy, end = n === undefined ? xy.length / 2 : Math.min(n, xy.length / 2);
+  ctx.save();
+  if (s.b) ctx.globalCompositeOperation = s.b;
+  var paint = s.c;
+  if (s.g) {
+    var c = s.g.xy;
+    paint = s.g.r ? ctx.createRadialGradient(c[0], c[1], 0, c[0], c[1], c[2])
+                  : ctx.createLinearGradient(c[0], c[1], c[2], c[3]);
+    s.g.s.forEach(function (st) { paint.addColorStop(+st[0], st[1]); });
+  }
+  if (s.k === "text") {
+    ctx.font = s.f;
+    ctx.fillStyle = paint;
+    ctx.textAlign = s.a || "left";
+    ctx.fillText(s.t, xy[0], xy[1]);
+  } else if (s.k === "fill") {
+    ctx.beginPath();
+    for (var i = 0; i < end; i++) ctx.lineTo(xy[2*i], xy[2*i+1]);
+    ctx.closePath();
+    ctx.fillStyle = paint;
+    ctx.fill();
+  } else {
+    ctx.lineWidth = s.w;
+    ctx.lineCap = s.cap || "round";
+    ctx.lineJoin = s.join || "round";
+    if (s.ml) ctx.miterLimit = s.ml;
+    if (s.d) ctx.setLineDash(s.d);
+    if (s.cs) {
+      for (var i = 1; i < end; i++) {
+        ctx.beginPath();
+        ctx.moveTo(xy[2*i-2], xy[2*i-1]);
+        ctx.lineTo(xy[2*i], xy[2*i+1]);
+        ctx.strokeStyle = s.cs[i];
+        ctx.stroke();
+      }
+    } else {
+      ctx.beginPath();
+      for (var i = 0; i < end; i++) ctx.lineTo(xy[2*i], xy[2*i+1]);
+      ctx.strokeStyle = paint;
+      ctx.stroke();
+    }
+  }
+  ctx.restore();
+}
+`
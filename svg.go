@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+// svgWriter writes a recording as SVG. Points are flipped from world to
+// image coordinates, so the SVG matches the PNG pixel for pixel.
+type svgWriter struct {
+	w      *bufio.Writer
+	height float64
+	nextID int
+}
+
+// writeSVG writes the records drawn on the layers of s named in names, or
+// on all visible layers if names is nil, as an SVG document of the given
+// size, or of the size of s if size is zero.
+func writeSVG(w io.Writer, s *layerStack, names []string, records []*record, size image.Point) error {
+	sw := &svgWriter{w: bufio.NewWriter(w), height: float64(s.bounds.Dy())}
+	width, height := s.bounds.Dx(), s.bounds.Dy()
+	if size == (image.Point{}) {
+		size = s.bounds.Size()
+	}
+	fmt.Fprintf(sw.w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", size.X, size.Y, width, height)
+	fmt.Fprintf(sw.w, "<rect width=\"100%%\" height=\"100%%\" %s/>\n", svgPaint("fill", s.background, 1))
+
+	s.mu.Lock()
+	layers := s.ordered()
+	s.mu.Unlock()
+	for _, l := range layers {
+		if names == nil && !l.visible || names != nil && !contains(names, l.name) {
+			continue
+		}
+		fmt.Fprintf(sw.w, "<g id=\"%s\"", l.name)
+		if l.opacity < 1 {
+			fmt.Fprintf(sw.w, " opacity=\"%s\"", num(l.opacity))
+		}
+		fmt.Fprintln(sw.w, ">")
+		for _, r := range records {
+			if r.part == l.name {
+				sw.record(r)
+			}
+		}
+		fmt.Fprintln(sw.w, "</g>")
+	}
+	fmt.Fprintln(sw.w, "</svg>")
+	return sw.w.Flush()
+}
+
+// pt converts a world point to SVG coordinates.
+func (sw *svgWriter) pt(p vec) vec {
+	return vec{p.x + 0.5, sw.height - p.y - 0.5}
+}
+
+func (sw *svgWriter) id() string {
+	sw.nextID++
+	return fmt.Sprintf("g%d", sw.nextID)
+}
+
+func (sw *svgWriter) record(r *record) {
+	switch r.kind {
+	case recordText:
+		sw.text(r)
+	case recordPath:
+		if len(r.points) < 2 {
+			return
+		}
+		if r.gradient != nil && r.gradient.kind == gradientAlong {
+			sw.alongPath(r)
+			return
+		}
+		d := sw.pathData(r.points)
+		if r.curve {
+			d = sw.curveData(r.points)
+		}
+		fmt.Fprintf(sw.w, "<path d=\"%s\" fill=\"none\" %s %s/>\n", d, sw.paint("stroke", r), strokeAttrs(r))
+	case recordFill:
+		if len(r.points) < 3 {
+			return
+		}
+		attrs := sw.paint("fill", r)
+		if mode := svgBlend(r.blend); mode != "" {
+			attrs += fmt.Sprintf(" style=\"mix-blend-mode:%s\"", mode)
+		}
+		fmt.Fprintf(sw.w, "<path d=\"%s Z\" stroke=\"none\" %s/>\n", sw.pathData(r.points), attrs)
+	}
+}
+
+// paint returns the attributes setting the paint attr to the color or the
+// gradient of r, and its opacity, writing the gradient definition first. A
+// gradient running along strokes paints a fill with its first color.
+func (sw *svgWriter) paint(attr string, r *record) string {
+	g := r.gradient
+	switch {
+	case g == nil:
+		return svgPaint(attr, r.color, r.opacity)
+	case g.kind == gradientAlong:
+		return svgPaint(attr, g.colorAt(0).rgba(), r.opacity)
+	}
+	return fmt.Sprintf("%s=\"url(#%s)\"", attr, sw.gradient(g)) + opacityAttr(attr+"-opacity", r.opacity)
+}
+
+// alongPath writes a stroke whose gradient runs along it. SVG has no such
+// paint, so each segment is written with a linear gradient going from the
+// color at its start to the one at its end.
+func (sw *svgWriter) alongPath(r *record) {
+	g := r.gradient
+	fmt.Fprintf(sw.w, "<g %s%s>\n", strokeAttrs(r), opacityAttr("stroke-opacity", r.opacity))
+	dist := 0.0
+	for i := 1; i < len(r.points); i++ {
+		a, b := r.points[i-1], r.points[i]
+		l := b.sub(a).length()
+		t0, t1 := g.offset(a, dist), g.offset(b, dist+l)
+		dist += l
+		if l == 0 {
+			continue
+		}
+		id := sw.id()
+		pa, pb := sw.pt(a), sw.pt(b)
+		fmt.Fprintf(sw.w, "<linearGradient id=\"%s\" gradientUnits=\"userSpaceOnUse\" x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\">", id, num(pa.x), num(pa.y), num(pb.x), num(pb.y))
+		sw.stops(g, t0, t1)
+		fmt.Fprintln(sw.w, "</linearGradient>")
+		fmt.Fprintf(sw.w, "<path d=\"%s\" fill=\"none\" stroke=\"url(#%s)\"/>\n", sw.pathData([]vec{a, b}), id)
+	}
+	fmt.Fprintln(sw.w, "</g>")
+}
+
+// gradient writes the definition of a canvas gradient and returns its id.
+func (sw *svgWriter) gradient(g *gradient) string {
+	id := sw.id()
+	spread := ""
+	if g.spread != spreadPad {
+		spread = fmt.Sprintf(" spreadMethod=\"%s\"", g.spread)
+	}
+	switch g.kind {
+	case gradientRadial:
+		c := sw.pt(g.p0)
+		fmt.Fprintf(sw.w, "<defs><radialGradient id=\"%s\" gradientUnits=\"userSpaceOnUse\" cx=\"%s\" cy=\"%s\" r=\"%s\"%s>", id, num(c.x), num(c.y), num(g.radius), spread)
+		sw.stops(g, 0, 1)
+		fmt.Fprintln(sw.w, "</radialGradient></defs>")
+	default:
+		p0, p1 := sw.pt(g.p0), sw.pt(g.p1)
+		fmt.Fprintf(sw.w, "<defs><linearGradient id=\"%s\" gradientUnits=\"userSpaceOnUse\" x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\"%s>", id, num(p0.x), num(p0.y), num(p1.x), num(p1.y), spread)
+		sw.stops(g, 0, 1)
+		fmt.Fprintln(sw.w, "</linearGradient></defs>")
+	}
+	return id
+}
+
+// svgStopsPerSpan is how many stops approximate the part of a gradient
+// between two of its own stops when SVG cannot interpolate it the same
+// way: in HSV, or when spreading along a path.
+const svgStopsPerSpan = 8
+
+// stops writes the stops of g between gradient offsets t0 and t1, mapped to
+// SVG offsets 0 to 1.
+func (sw *svgWriter) stops(g *gradient, t0, t1 float64) {
+	if t0 == 0 && t1 == 1 && !g.hsv {
+		for _, s := range g.stops {
+			fmt.Fprintf(sw.w, "<stop offset=\"%s\" %s/>", num(s.offset), svgPaint("stop-color", s.color, 1))
+		}
+		return
+	}
+	// sample often enough to catch every stop crossed
+	n := svgStopsPerSpan * (len(g.stops) + int(math.Ceil(math.Abs(t1-t0))))
+	for i := 0; i <= n; i++ {
+		t := t0 + (t1-t0)*float64(i)/float64(n)
+		c := g.colorAt(t)
+		fmt.Fprintf(sw.w, "<stop offset=\"%s\" %s/>", num(float64(i)/float64(n)), svgPaint("stop-color", c.rgba(), 1))
+	}
+}
+
+func (sw *svgWriter) text(r *record) {
+	p := sw.pt(r.points[0])
+	anchor := map[textAlign]string{alignLeft: "start", alignCenter: "middle", alignRight: "end"}[r.align]
+	fmt.Fprintf(sw.w, "<text x=\"%s\" y=\"%s\" font-family=\"%s\" font-size=\"%s\" text-anchor=\"%s\" %s>%s</text>\n",
+		num(p.x), num(p.y), svgFontFamily(r.font.face), num(r.font.size), anchor,
+		svgPaint("fill", r.color, r.opacity), xmlEscape(r.text))
+}
+
+// pathData returns the SVG path data of a polyline.
+func (sw *svgWriter) pathData(points []vec) string {
+	var b strings.Builder
+	for i, p := range points {
+		p = sw.pt(p)
+		if i == 0 {
+			b.WriteString("M")
+		} else {
+			b.WriteString(" L")
+		}
+		fmt.Fprintf(&b, "%s %s", num(p.x), num(p.y))
+	}
+	return b.String()
+}
+
+// curveData returns the SVG path data of the Catmull-Rom spline through
+// points.
+func (sw *svgWriter) curveData(points []vec) string {
+	var b strings.Builder
+	p := sw.pt(points[0])
+	fmt.Fprintf(&b, "M%s %s", num(p.x), num(p.y))
+	for i, c := range catmullRom(points) {
+		c1, c2, p := sw.pt(c[0]), sw.pt(c[1]), sw.pt(points[i+1])
+		fmt.Fprintf(&b, " C%s %s %s %s %s %s", num(c1.x), num(c1.y), num(c2.x), num(c2.y), num(p.x), num(p.y))
+	}
+	return b.String()
+}
+
+// strokeAttrs returns the presentation attributes of the pen of r, except
+// its paint and opacity.
+func strokeAttrs(r *record) string {
+	st := r.style
+	attrs := []string{
+		fmt.Sprintf("stroke-width=\"%s\"", num(st.width)),
+		fmt.Sprintf("stroke-linecap=\"%s\"", st.cap),
+		fmt.Sprintf("stroke-linejoin=\"%s\"", st.join),
+	}
+	if st.join == joinMiter {
+		attrs = append(attrs, fmt.Sprintf("stroke-miterlimit=\"%s\"", num(st.miterLimit)))
+	}
+	if len(st.dash) > 0 {
+		var ds []string
+		for _, d := range st.dash {
+			ds = append(ds, num(d))
+		}
+		attrs = append(attrs, fmt.Sprintf("stroke-dasharray=\"%s\"", strings.Join(ds, " ")))
+		if st.dashPhase != 0 {
+			attrs = append(attrs, fmt.Sprintf("stroke-dashoffset=\"%s\"", num(st.dashPhase)))
+		}
+	}
+	if mode := svgBlend(r.blend); mode != "" {
+		attrs = append(attrs, fmt.Sprintf("style=\"mix-blend-mode:%s\"", mode))
+	}
+	return strings.Join(attrs, " ")
+}
+
+// svgBlend returns the CSS blend mode matching m. Erasing has no CSS
+// equivalent on a single element and is drawn as source-over.
+func svgBlend(m blendMode) string {
+	switch m {
+	case blendMultiply:
+		return "multiply"
+	case blendScreen:
+		return "screen"
+	case blendAdd:
+		return "plus-lighter"
+	}
+	return ""
+}
+
+// svgPaint returns the attribute setting the paint attr to c and, if the
+// alpha of c times opacity is below 1, the one setting its opacity to it.
+func svgPaint(attr string, c color.Color, opacity float64) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	s := fmt.Sprintf("%s=\"#%02x%02x%02x\"", attr, n.R, n.G, n.B)
+	return s + opacityAttr(strings.TrimSuffix(attr, "-color")+"-opacity", float64(n.A)/0xff*opacity)
+}
+
+// opacityAttr returns the attribute setting attr to the opacity o, with a
+// leading space, or nothing if o is 1.
+func opacityAttr(attr string, o float64) string {
+	if o >= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" %s=\"%s\"", attr, num(o))
+}
+
+// svgFontFamily returns the CSS font family of a face, with a generic
+// fallback.
+func svgFontFamily(face string) string {
+	if strings.Contains(face, "Mono") {
+		return xmlEscape(face) + ", monospace"
+	}
+	return xmlEscape(face) + ", sans-serif"
+}
+
+// num formats v with at most two decimals.
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
+
+// svgCmd renders the drawing and writes it as SVG.
+func svgCmd(args []string) error {
+	fs := flag.NewFlagSet("svg", flag.ExitOnError)
+	out := fs.String("out", "bdd-go.svg", "output file")
+	only := fs.String("layers", "", "comma separated layers to export, default all")
+	merge := fs.Bool("merge", true, "join strokes drawn one after the other with the same pen")
+	tolerance := fs.Float64("simplify", 0, "simplify strokes, letting them stray this many pixels")
+	smooth := fs.Bool("smooth", false, "draw strokes as smooth curves through their points")
+	fs.Parse(args)
+
+	s := newCanvas()
+	names, err := layerNames(s, *only)
+	if err != nil {
+		return err
+	}
+	drawParts(s, pacing{mode: paceNone}, nil, nil)
+	records, stats := simplifyRecords(s.rec.list(), simplifyOptions{
+		merge:     *merge,
+		tolerance: *tolerance,
+		smooth:    *smooth,
+	})
+	log.Print(stats)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeSVG(f, s, names, records, image.Point{}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// pathOp is one command of an imported path.
+type pathOp int
+
+const (
+	opMove  pathOp = iota // pts[0]
+	opLine                // pts[0]
+	opCubic               // control points pts[0], pts[1], end pts[2]
+	opClose               // back to the start of the subpath
+)
+
+type pathCmd struct {
+	op  pathOp
+	pts []vec
+}
+
+// importedShape is an SVG element converted to world coordinates, with
+// the paint it is drawn with. A nil stroke or fill is not drawn.
+type importedShape struct {
+	stroke color.Color
+	width  float64
+	fill   color.Color
+	cmds   []pathCmd
+}
+
+// draw draws the shape with t, filling it first and then stroking it, as
+// SVG paints them.
+func (sh *importedShape) draw(t *artist) {
+	t.PenUp()
+	if sh.fill != nil {
+		t.SetFillColor(sh.fill)
+		sh.trace(t, true)
+		t.SetFillColor(nil)
+	}
+	if sh.stroke != nil && sh.width > 0 {
+		t.SetColor(sh.stroke)
+		t.SetWidth(sh.width)
+		sh.trace(t, false)
+	}
+}
+
+// trace runs the commands of the shape with t. To fill, the turtle traces
+// the whole shape with the pen up, each subpath closed; otherwise it
+// strokes it, lifting the pen between subpaths.
+func (sh *importedShape) trace(t *artist, fill bool) {
+	var start vec
+	for i, c := range sh.cmds {
+		switch c.op {
+		case opMove:
+			if fill && i > 0 {
+				t.lineTo(start.x, start.y)
+			}
+			t.PenUp()
+			t.SetPos(c.pts[0].x, c.pts[0].y)
+			start = c.pts[0]
+			if fill && i == 0 {
+				t.BeginFill()
+			}
+			continue
+		}
+		if !fill {
+			t.PenDown()
+		}
+		switch c.op {
+		case opLine:
+			t.lineTo(c.pts[0].x, c.pts[0].y)
+		case opCubic:
+			t.CubicTo(c.pts[0].x, c.pts[0].y, c.pts[1].x, c.pts[1].y, c.pts[2].x, c.pts[2].y)
+		case opClose:
+			t.lineTo(start.x, start.y)
+		}
+	}
+	if fill {
+		t.EndFill()
+	}
+	t.PenUp()
+}
+
+// svgUndrawn are the elements whose content is only drawn when referred to,
+// which the import skips.
+var svgUndrawn = map[string]bool{
+	"defs":     true,
+	"clipPath": true,
+	"mask":     true,
+	"symbol":   true,
+	"marker":   true,
+	"pattern":  true,
+}
+
+// importSVG reads the path, circle, ellipse, polyline and polygon elements
+// of an SVG document outside definitions, with their transforms, as shapes
+// in the world coordinates of a canvas height pixels high.
+func importSVG(r io.Reader, height float64) ([]*importedShape, error) {
+	d := xml.NewDecoder(r)
+	// the transform and paint of the enclosing groups
+	type group struct {
+		m     affine
+		style svgStyle
+	}
+	stack := []group{{m: identity, style: svgStyle{fill: color.Color(color.Black), width: 1}}}
+	var shapes []*importedShape
+	// how deep in an element whose content is not drawn where it is
+	skip := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 || svgUndrawn[tok.Name.Local] {
+				skip++
+				continue
+			}
+			attrs := map[string]string{}
+			for _, a := range tok.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			top := stack[len(stack)-1]
+			g := group{m: top.m, style: top.style.with(attrs)}
+			if tok.Name.Local == "svg" && len(stack) == 1 {
+				g.m = viewBoxTransform(attrs)
+			}
+			if tr, ok := attrs["transform"]; ok {
+				m, err := parseTransform(tr)
+				if err != nil {
+					return nil, err
+				}
+				g.m = g.m.mul(m)
+			}
+			stack = append(stack, g)
+
+			cmds, err := elementPath(tok.Name.Local, attrs)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", tok.Name.Local, err)
+			}
+			if len(cmds) == 0 {
+				continue
+			}
+			sh := &importedShape{stroke: g.style.stroke, fill: g.style.fill}
+			if tok.Name.Local == "polyline" || tok.Name.Local == "line" {
+				sh.fill = nil
+			}
+			// widths scale with the mean scale of the transform
+			sh.width = g.style.width * math.Sqrt(math.Abs(g.m.det()))
+			for _, c := range cmds {
+				w := pathCmd{op: c.op}
+				for _, p := range c.pts {
+					p = g.m.apply(p)
+					// as in svgWriter.pt, the other way round
+					w.pts = append(w.pts, vec{p.x - 0.5, height - p.y - 0.5})
+				}
+				sh.cmds = append(sh.cmds, w)
+			}
+			shapes = append(shapes, sh)
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return shapes, nil
+}
+
+// importSVGFile reads the shapes of an SVG file.
+func importSVGFile(path string, height float64) ([]*importedShape, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	shapes, err := importSVG(f, height)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return shapes, nil
+}
+
+// elementPath returns the outline of an element, in its user coordinates,
+// or nothing if it is not a shape.
+func elementPath(name string, attrs map[string]string) ([]pathCmd, error) {
+	num := func(key string) float64 {
+		v, _ := parseLength(attrs[key])
+		return v
+	}
+	switch name {
+	case "path":
+		return parsePathData(attrs["d"])
+	case "circle":
+		r := num("r")
+		return ellipsePath(num("cx"), num("cy"), r, r), nil
+	case "ellipse":
+		return ellipsePath(num("cx"), num("cy"), num("rx"), num("ry")), nil
+	case "line":
+		return []pathCmd{
+			{opMove, []vec{{num("x1"), num("y1")}}},
+			{opLine, []vec{{num("x2"), num("y2")}}},
+		}, nil
+	case "polyline", "polygon":
+		vs, err := parseNumbers(attrs["points"])
+		if err != nil {
+			return nil, err
+		}
+		var cmds []pathCmd
+		for i := 0; i+1 < len(vs); i += 2 {
+			op := opLine
+			if i == 0 {
+				op = opMove
+			}
+			cmds = append(cmds, pathCmd{op, []vec{{vs[i], vs[i+1]}}})
+		}
+		if name == "polygon" && len(cmds) > 0 {
+			cmds = append(cmds, pathCmd{op: opClose})
+		}
+		return cmds, nil
+	}
+	return nil, nil
+}
+
+// ellipsePath returns an ellipse as four cubic curves.
+func ellipsePath(cx, cy, rx, ry float64) []pathCmd {
+	if rx <= 0 || ry <= 0 {
+		return nil
+	}
+	// control distance of a quarter circle of radius 1
+	const k = 0.5522847498
+	c := vec{cx, cy}
+	pt := func(x, y float64) vec { return c.add(vec{x * rx, y * ry}) }
+	return []pathCmd{
+		{opMove, []vec{pt(1, 0)}},
+		{opCubic, []vec{pt(1, k), pt(k, 1), pt(0, 1)}},
+		{opCubic, []vec{pt(-k, 1), pt(-1, k), pt(-1, 0)}},
+		{opCubic, []vec{pt(-1, -k), pt(-k, -1), pt(0, -1)}},
+		{opCubic, []vec{pt(k, -1), pt(1, -k), pt(1, 0)}},
+		{op: opClose},
+	}
+}
+
+// svgStyle is the paint an element inherits and sets.
+type svgStyle struct {
+	stroke, fill color.Color
+	width        float64
+}
+
+// with returns the style of an element with the given attributes, inside
+// an element of style s. Properties set in the style attribute win over
+// presentation attributes.
+func (s svgStyle) with(attrs map[string]string) svgStyle {
+	props := map[string]string{}
+	for _, k := range []string{"stroke", "fill", "stroke-width", "stroke-opacity", "fill-opacity", "opacity"} {
+		if v, ok := attrs[k]; ok {
+			props[k] = v
+		}
+	}
+	for _, decl := range strings.Split(attrs["style"], ";") {
+		if i := strings.IndexByte(decl, ':'); i >= 0 {
+			props[strings.TrimSpace(decl[:i])] = strings.TrimSpace(decl[i+1:])
+		}
+	}
+	if v, ok := props["stroke"]; ok {
+		s.stroke = parseSVGColor(v)
+	}
+	if v, ok := props["fill"]; ok {
+		s.fill = parseSVGColor(v)
+	}
+	if v, ok := props["stroke-width"]; ok {
+		if w, err := parseLength(v); err == nil {
+			s.width = w
+		}
+	}
+	opacity := func(key string) float64 {
+		o, err := strconv.ParseFloat(props[key], 64)
+		if err != nil {
+			return 1
+		}
+		return math.Max(0, math.Min(1, o))
+	}
+	all := opacity("opacity")
+	s.stroke = fade(s.stroke, all*opacity("stroke-opacity"))
+	s.fill = fade(s.fill, all*opacity("fill-opacity"))
+	return s
+}
+
+// fade returns c with its alpha multiplied by o.
+func fade(c color.Color, o float64) color.Color {
+	if c == nil || o >= 1 {
+		return c
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(math.Round(float64(n.A) * o))
+	return n
+}
+
+// svgColorNames are the named colors understood by the importer.
+var svgColorNames = map[string]color.Color{
+	"black":   color.Black,
+	"white":   color.White,
+	"red":     color.RGBA{0xff, 0, 0, 0xff},
+	"green":   color.RGBA{0, 0x80, 0, 0xff},
+	"lime":    color.RGBA{0, 0xff, 0, 0xff},
+	"blue":    color.RGBA{0, 0, 0xff, 0xff},
+	"yellow":  color.RGBA{0xff, 0xff, 0, 0xff},
+	"cyan":    color.RGBA{0, 0xff, 0xff, 0xff},
+	"magenta": color.RGBA{0xff, 0, 0xff, 0xff},
+	"orange":  color.RGBA{0xff, 0xa5, 0, 0xff},
+	"purple":  color.RGBA{0x80, 0, 0x80, 0xff},
+	"gray":    color.RGBA{0x80, 0x80, 0x80, 0xff},
+	"grey":    color.RGBA{0x80, 0x80, 0x80, 0xff},
+	"pink":    color.RGBA{0xff, 0xc0, 0xcb, 0xff},
+	"brown":   color.RGBA{0xa5, 0x2a, 0x2a, 0xff},
+	"navy":    color.RGBA{0, 0, 0x80, 0xff},
+	"teal":    color.RGBA{0, 0x80, 0x80, 0xff},
+	"olive":   color.RGBA{0x80, 0x80, 0, 0xff},
+	"maroon":  color.RGBA{0x80, 0, 0, 0xff},
+	"silver":  color.RGBA{0xc0, 0xc0, 0xc0, 0xff},
+}
+
+// parseSVGColor reads a paint: none, a hex color with or without alpha, an
+// rgb() color, or a color name. Gradients and patterns are not imported and read as none; other
+// paints it does not understand are black.
+func parseSVGColor(s string) color.Color {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "none" || s == "transparent" || strings.HasPrefix(s, "url("):
+		return nil
+	case strings.HasPrefix(s, "#"):
+		h := s[1:]
+		if len(h) == 3 || len(h) == 4 {
+			var b []byte
+			for i := range h {
+				b = append(b, h[i], h[i])
+			}
+			h = string(b)
+		}
+		if len(h) == 6 {
+			h += "ff"
+		}
+		v, err := strconv.ParseUint(h, 16, 32)
+		if err != nil || len(h) != 8 {
+			break
+		}
+		return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		var c [3]uint8
+		fields := strings.Split(s[4:len(s)-1], ",")
+		if len(fields) != 3 {
+			break
+		}
+		for i, f := range fields {
+			f = strings.TrimSpace(f)
+			scale := 1.0
+			if strings.HasSuffix(f, "%") {
+				f, scale = strings.TrimSuffix(f, "%"), 2.55
+			}
+			v, _ := strconv.ParseFloat(f, 64)
+			c[i] = uint8(math.Max(0, math.Min(255, math.Round(v*scale))))
+		}
+		return color.RGBA{c[0], c[1], c[2], 0xff}
+	}
+	if c, ok := svgColorNames[s]; ok {
+		return c
+	}
+	return color.Black
+}
+
+// parseLength reads a length, ignoring its unit.
+func parseLength(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	end := len(s)
+	for end > 0 && (unicode.IsLetter(rune(s[end-1])) || s[end-1] == '%') {
+		end--
+	}
+	return strconv.ParseFloat(s[:end], 64)
+}
+
+// viewBoxTransform maps the viewBox of the root element onto its width and
+// height, following its preserveAspectRatio.
+func viewBoxTransform(attrs map[string]string) affine {
+	vb, err := parseNumbers(attrs["viewBox"])
+	if err != nil || len(vb) != 4 || vb[2] <= 0 || vb[3] <= 0 {
+		return identity
+	}
+	w, errW := parseLength(attrs["width"])
+	h, errH := parseLength(attrs["height"])
+	if errW != nil || errH != nil {
+		return affine{1, 0, 0, 1, -vb[0], -vb[1]}
+	}
+	sx, sy := w/vb[2], h/vb[3]
+	fields := strings.Fields(attrs["preserveAspectRatio"])
+	if len(fields) > 0 && fields[0] == "defer" {
+		fields = fields[1:]
+	}
+	align, slice := "xMidYMid", false
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if len(fields) > 1 {
+		slice = fields[1] == "slice"
+	}
+	if align == "none" {
+		return affine{sx, 0, 0, sy, -vb[0] * sx, -vb[1] * sy}
+	}
+	// one scale, showing the whole viewBox or filling the viewport
+	s := math.Min(sx, sy)
+	if slice {
+		s = math.Max(sx, sy)
+	}
+	// where the viewBox goes in the room left over, along x and y
+	at := func(min, mid string) float64 {
+		switch {
+		case strings.Contains(align, min):
+			return 0
+		case strings.Contains(align, mid):
+			return 0.5
+		}
+		return 1
+	}
+	ex := (w-vb[2]*s)*at("xMin", "xMid") - vb[0]*s
+	ey := (h-vb[3]*s)*at("YMin", "YMid") - vb[1]*s
+	return affine{s, 0, 0, s, ex, ey}
+}
+
+// affine is the transform x' = a*x + c*y + e, y' = b*x + d*y + f, as in
+// the SVG matrix(a b c d e f).
+type affine struct {
+	a, b, c, d, e, f float64
+}
+
+var identity = affine{a: 1, d: 1}
+
+func (m affine) apply(p vec) vec {
+	return vec{m.a*p.x + m.c*p.y + m.e, m.b*p.x + m.d*p.y + m.f}
+}
+
+// mul returns the transform applying n, then m.
+func (m affine) mul(n affine) affine {
+	return affine{
+		a: m.a*n.a + m.c*n.b,
+		b: m.b*n.a + m.d*n.b,
+		c: m.a*n.c + m.c*n.d,
+		d: m.b*n.c + m.d*n.d,
+		e: m.a*n.e + m.c*n.f + m.e,
+		f: m.b*n.e + m.d*n.f + m.f,
+	}
+}
+
+func (m affine) det() float64 {
+	return m.a*m.d - m.b*m.c
+}
+
+// parseTransform reads a transform attribute: a list of matrix, translate,
+// scale, rotate, skewX and skewY functions, applied right to left.
+func parseTransform(s string) (affine, error) {
+	m := identity
+	for {
+		s = strings.TrimLeft(s, " \t\r\n,")
+		if s == "" {
+			return m, nil
+		}
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return m, fmt.Errorf("bad transform %q", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		args, err := parseNumbers(s[open+1 : end])
+		if err != nil {
+			return m, err
+		}
+		s = s[end+1:]
+
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var t affine
+		switch name {
+		case "matrix":
+			if len(args) != 6 {
+				return m, fmt.Errorf("matrix wants 6 numbers, got %d", len(args))
+			}
+			t = affine{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case "translate":
+			t = affine{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			t = affine{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			r := arg(0, 0) * math.Pi / 180
+			cos, sin := math.Cos(r), math.Sin(r)
+			cx, cy := arg(1, 0), arg(2, 0)
+			t = affine{1, 0, 0, 1, cx, cy}.
+				mul(affine{cos, sin, -sin, cos, 0, 0}).
+				mul(affine{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = affine{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = affine{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m, fmt.Errorf("unknown transform %q", name)
+		}
+		m = m.mul(t)
+	}
+}
+
+// parseNumbers reads a list of numbers separated by spaces or commas, or
+// by nothing where the next number starts with a sign or a second dot.
+func parseNumbers(s string) ([]float64, error) {
+	sc := &numScanner{s: s}
+	var vs []float64
+	for {
+		sc.skip()
+		if sc.done() {
+			return vs, nil
+		}
+		v, err := sc.number()
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+}
+
+// numScanner reads the numbers of path data and attribute lists.
+type numScanner struct {
+	s string
+	i int
+}
+
+func (sc *numScanner) done() bool { return sc.i >= len(sc.s) }
+
+// skip skips separators.
+func (sc *numScanner) skip() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+// number reads the number at the current position.
+func (sc *numScanner) number() (float64, error) {
+	sc.skip()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+		sc.i++
+	}
+	dot, exp := false, false
+scan:
+	for ; sc.i < len(sc.s); sc.i++ {
+		ch := sc.s[sc.i]
+		switch {
+		case ch >= '0' && ch <= '9':
+		case ch == '.' && !dot && !exp:
+			dot = true
+		case (ch == 'e' || ch == 'E') && !exp && sc.i > start:
+			exp = true
+			if sc.i+1 < len(sc.s) && (sc.s[sc.i+1] == '+' || sc.s[sc.i+1] == '-') {
+				sc.i++
+			}
+		default:
+			break scan
+		}
+	}
+	v, err := strconv.ParseFloat(sc.s[start:sc.i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad number at %q", sc.s[start:])
+	}
+	return v, nil
+}
+
+// flag reads an arc flag, which may be written without a separator.
+func (sc *numScanner) flag() (bool, error) {
+	sc.skip()
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '0' || sc.s[sc.i] == '1') {
+		sc.i++
+		return sc.s[sc.i-1] == '1', nil
+	}
+	return false, fmt.Errorf("bad arc flag at %q", sc.s[sc.i:])
+}
+
+// parsePathData reads SVG path data as moves, lines and cubic curves.
+func parsePathData(d string) ([]pathCmd, error) {
+	sc := &numScanner{s: d}
+	var (
+		cmds       []pathCmd
+		cur, start vec
+		ctrl       vec // last control point, for smooth curves
+		last       byte
+		op         byte
+	)
+	for {
+		sc.skip()
+		if sc.done() {
+			return cmds, nil
+		}
+		if ch := sc.s[sc.i]; unicode.IsLetter(rune(ch)) && ch != 'e' && ch != 'E' {
+			op = ch
+			sc.i++
+		} else if op == 0 {
+			return nil, fmt.Errorf("path data does not start with a command: %q", d)
+		}
+		rel := op >= 'a'
+		read := func(n int) ([]float64, error) {
+			vs := make([]float64, n)
+			for i := range vs {
+				v, err := sc.number()
+				if err != nil {
+					return nil, err
+				}
+				vs[i] = v
+			}
+			return vs, nil
+		}
+		pt := func(x, y float64) vec {
+			if rel {
+				return cur.add(vec{x, y})
+			}
+			return vec{x, y}
+		}
+
+		upper := op &^ 0x20
+		switch upper {
+		case 'Z':
+			cmds = append(cmds, pathCmd{op: opClose})
+			cur = start
+			last = 'Z'
+			// a command letter must follow
+			op = 0
+			continue
+		case 'M', 'L', 'T':
+			v, err := read(2)
+			if err != nil {
+				return nil, err
+			}
+			p := pt(v[0], v[1])
+			switch {
+			case upper == 'M':
+				cmds = append(cmds, pathCmd{opMove, []vec{p}})
+				start = p
+				// further pairs are lines
+				if rel {
+					op = 'l'
+				} else {
+					op = 'L'
+				}
+			case upper == 'T':
+				c := cur
+				if last == 'Q' || last == 'T' {
+					c = cur.scale(2).sub(ctrl)
+				}
+				cmds = append(cmds, quadCmd(cur, c, p))
+				ctrl = c
+			default:
+				cmds = append(cmds, pathCmd{opLine, []vec{p}})
+			}
+			cur = p
+		case 'H', 'V':
+			v, err := read(1)
+			if err != nil {
+				return nil, err
+			}
+			p := cur
+			switch {
+			case upper == 'H' && rel:
+				p.x += v[0]
+			case upper == 'H':
+				p.x = v[0]
+			case rel:
+				p.y += v[0]
+			default:
+				p.y = v[0]
+			}
+			cmds = append(cmds, pathCmd{opLine, []vec{p}})
+			cur = p
+		case 'C', 'S':
+			n := 6
+			if upper == 'S' {
+				n = 4
+			}
+			v, err := read(n)
+			if err != nil {
+				return nil, err
+			}
+			var c1 vec
+			if upper == 'S' {
+				c1 = cur
+				if last == 'C' || last == 'S' {
+					c1 = cur.scale(2).sub(ctrl)
+				}
+				v = append([]float64{0, 0}, v...)
+			} else {
+				c1 = pt(v[0], v[1])
+			}
+			c2, p := pt(v[2], v[3]), pt(v[4], v[5])
+			cmds = append(cmds, pathCmd{opCubic, []vec{c1, c2, p}})
+			ctrl, cur = c2, p
+		case 'Q':
+			v, err := read(4)
+			if err != nil {
+				return nil, err
+			}
+			c, p := pt(v[0], v[1]), pt(v[2], v[3])
+			cmds = append(cmds, quadCmd(cur, c, p))
+			ctrl, cur = c, p
+		case 'A':
+			v, err := read(3)
+			if err != nil {
+				return nil, err
+			}
+			large, err := sc.flag()
+			if err != nil {
+				return nil, err
+			}
+			sweep, err := sc.flag()
+			if err != nil {
+				return nil, err
+			}
+			e, err := read(2)
+			if err != nil {
+				return nil, err
+			}
+			p := pt(e[0], e[1])
+			cmds = append(cmds, arcCmds(cur, v[0], v[1], v[2], large, sweep, p)...)
+			cur = p
+		default:
+			return nil, fmt.Errorf("unknown path command %q", op)
+		}
+		last = upper
+	}
+}
+
+// quadCmd returns the quadratic curve from p0 through control c to p as a
+// cubic.
+func quadCmd(p0, c, p vec) pathCmd {
+	return pathCmd{opCubic, []vec{
+		p0.add(c.sub(p0).scale(2.0 / 3)),
+		p.add(c.sub(p).scale(2.0 / 3)),
+		p,
+	}}
+}
+
+// arcCmds returns the elliptical arc of the SVG A command from p0 to p as
+// cubic curves, following the endpoint to center conversion of the SVG
+// specification.
+func arcCmds(p0 vec, rx, ry, rotation float64, large, sweep bool, p vec) []pathCmd {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || p0 == p {
+		return []pathCmd{{opLine, []vec{p}}}
+	}
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	// the midpoint in the frame of the ellipse
+	h := p0.sub(p).scale(0.5)
+	x1 := cos*h.x + sin*h.y
+	y1 := -sin*h.x + cos*h.y
+	// grow radii too small to reach
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	k := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cx1, cy1 := k*rx*y1/ry, -k*ry*x1/rx
+	m := p0.add(p).scale(0.5)
+	c := vec{cos*cx1 - sin*cy1 + m.x, sin*cx1 + cos*cy1 + m.y}
+
+	angle := func(u, v vec) float64 { return math.Atan2(u.cross(v), u.dot(v)) }
+	u := vec{(x1 - cx1) / rx, (y1 - cy1) / ry}
+	v := vec{(-x1 - cx1) / rx, (-y1 - cy1) / ry}
+	theta := angle(vec{1, 0}, u)
+	delta := angle(u, v)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// one cubic per quarter turn at most
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	kk := 4.0 / 3 * math.Tan(step/4)
+	at := func(t float64) (pt, d vec) {
+		ex, ey := rx*math.Cos(t), ry*math.Sin(t)
+		dx, dy := -rx*math.Sin(t), ry*math.Cos(t)
+		return vec{cos*ex - sin*ey + c.x, sin*ex + cos*ey + c.y},
+			vec{cos*dx - sin*dy, sin*dx + cos*dy}
+	}
+	var cmds []pathCmd
+	for i := 0; i < n; i++ {
+		t0 := theta + step*float64(i)
+		a, da := at(t0)
+		b, db := at(t0 + step)
+		if i == n-1 {
+			b = p
+		}
+		cmds = append(cmds, pathCmd{opCubic, []vec{a.add(da.scale(kk)), b.sub(db.scale(kk)), b}})
+	}
+	return cmds
+}
+
+// addImportedPart adds a part drawing the shapes of an SVG file, on a
+// layer named import.
+func addImportedPart(path string) error {
+	shapes, err := importSVGFile(path, hight)
+	if err != nil {
+		return err
+	}
+	addPart("import", func(t *artist) {
+		for _, sh := range shapes {
+			sh.draw(t)
+		}
+	})
+	return nil
+}
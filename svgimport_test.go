@@ -0,0 +1,279 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func nearVec(p, q vec) bool {
+	return p.sub(q).length() < 1e-6
+}
+
+// ends returns the ops of cmds and the point each ends at; a close ends
+// nowhere and adds none.
+func ends(cmds []pathCmd) (ops []pathOp, pts []vec) {
+	for _, c := range cmds {
+		ops = append(ops, c.op)
+		if len(c.pts) > 0 {
+			pts = append(pts, c.pts[len(c.pts)-1])
+		}
+	}
+	return ops, pts
+}
+
+// cubicAt returns the point at t of the cubic curve from p0 of c.
+func cubicAt(p0 vec, c pathCmd, t float64) vec {
+	s := 1 - t
+	return p0.scale(s * s * s).
+		add(c.pts[0].scale(3 * s * s * t)).
+		add(c.pts[1].scale(3 * s * t * t)).
+		add(c.pts[2].scale(t * t * t))
+}
+
+func TestParsePathData(t *testing.T) {
+	tests := []struct {
+		d   string
+		ops []pathOp
+		pts []vec
+	}{
+		{"M10 20 L30 40 h10 v-10 Z", []pathOp{opMove, opLine, opLine, opLine, opClose},
+			[]vec{{10, 20}, {30, 40}, {40, 40}, {40, 30}}},
+		// the numbers after a move are lines, relative for m
+		{"m10 20 5 5 5 5", []pathOp{opMove, opLine, opLine}, []vec{{10, 20}, {15, 25}, {20, 30}}},
+		{"M1e1-5.5L.5.5", []pathOp{opMove, opLine}, []vec{{10, -5.5}, {0.5, 0.5}}},
+		{"M0 0 C1 2 3 4 5 6 s4 4 6 6", []pathOp{opMove, opCubic, opCubic}, []vec{{0, 0}, {5, 6}, {11, 12}}},
+		{"M0 0 Q5 10 10 0 T20 0", []pathOp{opMove, opCubic, opCubic}, []vec{{0, 0}, {10, 0}, {20, 0}}},
+		// a subpath after a close starts where the last one did
+		{"M5 5 l10 0 z l0 10", []pathOp{opMove, opLine, opClose, opLine}, []vec{{5, 5}, {15, 5}, {5, 15}}},
+	}
+	for _, tt := range tests {
+		cmds, err := parsePathData(tt.d)
+		if err != nil {
+			t.Errorf("parsePathData(%q): %v", tt.d, err)
+			continue
+		}
+		ops, pts := ends(cmds)
+		if len(ops) != len(tt.ops) || len(pts) != len(tt.pts) {
+			t.Errorf("parsePathData(%q) = %v ending at %v, want %v ending at %v", tt.d, ops, pts, tt.ops, tt.pts)
+			continue
+		}
+		for i := range ops {
+			if ops[i] != tt.ops[i] {
+				t.Errorf("parsePathData(%q) ops %v, want %v", tt.d, ops, tt.ops)
+				break
+			}
+		}
+		for i := range pts {
+			if !nearVec(pts[i], tt.pts[i]) {
+				t.Errorf("parsePathData(%q) ends at %v, want %v", tt.d, pts, tt.pts)
+				break
+			}
+		}
+	}
+
+	// S reflects the second control point of the curve before it
+	cmds, _ := parsePathData("M0 0 C1 2 3 4 5 6 S9 10 11 12")
+	if c := cmds[2].pts[0]; !nearVec(c, vec{7, 8}) {
+		t.Errorf("S first control point %v, want (7, 8)", c)
+	}
+	// Q is raised to a cubic with controls two thirds of the way to its own
+	cmds, _ = parsePathData("M0 0 Q6 9 12 0")
+	if c := cmds[1].pts; !nearVec(c[0], vec{4, 6}) || !nearVec(c[1], vec{8, 6}) {
+		t.Errorf("Q control points %v, want (4, 6) and (8, 6)", c[:2])
+	}
+
+	for _, d := range []string{"10 10", "M10", "M0 0 L1 x", "M0 0 A1 1 0 2 1 5 5"} {
+		if _, err := parsePathData(d); err == nil {
+			t.Errorf("parsePathData(%q) succeeds, want an error", d)
+		}
+	}
+}
+
+func TestArcCmds(t *testing.T) {
+	tests := []struct {
+		d      string
+		center vec
+		r      float64
+		curves int
+	}{
+		{"M0 0 A10 10 0 0 1 20 0", vec{10, 0}, 10, 2},
+		// radii too small to reach the end grow to fit
+		{"M0 0 A1 1 0 0 1 20 0", vec{10, 0}, 10, 2},
+		{"M0 0 A10 10 0 0 1 10 10", vec{0, 10}, 10, 1},
+		{"M0 0 A10 10 0 1 0 10 10", vec{0, 10}, 10, 3},
+		{"M0 0 A10 10 0 0 0 10 10", vec{10, 0}, 10, 1},
+		{"M0 0 a10 10 0 1 1 10 10", vec{10, 0}, 10, 3},
+	}
+	for _, tt := range tests {
+		cmds, err := parsePathData(tt.d)
+		if err != nil {
+			t.Errorf("parsePathData(%q): %v", tt.d, err)
+			continue
+		}
+		arc := cmds[1:]
+		if len(arc) != tt.curves {
+			t.Errorf("%q: %d curves, want %d", tt.d, len(arc), tt.curves)
+			continue
+		}
+		p0 := cmds[0].pts[0]
+		for _, c := range arc {
+			for _, at := range []float64{0.25, 0.5, 0.75, 1} {
+				p := cubicAt(p0, c, at)
+				// four cubics to a circle stray from it by less than 0.03%
+				if d := p.sub(tt.center).length(); math.Abs(d-tt.r) > tt.r*3e-4 {
+					t.Errorf("%q: point %v is %g from %v, want %g", tt.d, p, d, tt.center, tt.r)
+				}
+			}
+			p0 = c.pts[2]
+		}
+	}
+
+	// an arc with no radius is a line
+	cmds, _ := parsePathData("M0 0 A0 10 0 0 1 10 10")
+	if ops, pts := ends(cmds); len(ops) != 2 || ops[1] != opLine || !nearVec(pts[1], vec{10, 10}) {
+		t.Errorf("arc with no radius = %v ending at %v, want a line to (10, 10)", ops, pts)
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		s     string
+		p, to vec
+	}{
+		{"", vec{3, 4}, vec{3, 4}},
+		{"translate(10 20)", vec{1, 1}, vec{11, 21}},
+		{"translate(10)", vec{1, 1}, vec{11, 1}},
+		{"scale(2)", vec{1, 3}, vec{2, 6}},
+		{"scale(2,-1)", vec{1, 3}, vec{2, -3}},
+		{"rotate(90)", vec{1, 0}, vec{0, 1}},
+		{"rotate(90 10 10)", vec{20, 10}, vec{10, 20}},
+		{"matrix(1 2 3 4 5 6)", vec{1, 1}, vec{9, 12}},
+		{"skewX(45)", vec{0, 1}, vec{1, 1}},
+		{"skewY(45)", vec{1, 0}, vec{1, 1}},
+		// applied right to left: scale, then translate
+		{"translate(10 20) scale(2)", vec{1, 1}, vec{12, 22}},
+		{"scale(2), translate(10 20)", vec{1, 1}, vec{22, 42}},
+	}
+	for _, tt := range tests {
+		m, err := parseTransform(tt.s)
+		if err != nil {
+			t.Errorf("parseTransform(%q): %v", tt.s, err)
+			continue
+		}
+		if got := m.apply(tt.p); !nearVec(got, tt.to) {
+			t.Errorf("parseTransform(%q) maps %v to %v, want %v", tt.s, tt.p, got, tt.to)
+		}
+	}
+	for _, s := range []string{"spin(3)", "translate(1", "matrix(1 2 3)", "scale(x)"} {
+		if _, err := parseTransform(s); err == nil {
+			t.Errorf("parseTransform(%q) succeeds, want an error", s)
+		}
+	}
+}
+
+func TestViewBoxTransform(t *testing.T) {
+	tests := []struct {
+		viewBox, width, height, aspect string
+		p, to                          vec
+	}{
+		{"", "200", "100", "", vec{10, 10}, vec{10, 10}},
+		{"0 0 100 50", "200", "100", "", vec{100, 50}, vec{200, 100}},
+		{"10 10 100 100", "", "", "", vec{10, 10}, vec{0, 0}},
+		// meet centres the whole viewBox in the room left over
+		{"0 0 100 100", "200px", "100", "", vec{0, 0}, vec{50, 0}},
+		{"0 0 100 100", "200", "100", "xMinYMin", vec{100, 100}, vec{100, 100}},
+		{"0 0 100 100", "200", "100", "xMaxYMid meet", vec{0, 0}, vec{100, 0}},
+		// slice fills the viewport, cutting off the rest
+		{"0 0 100 100", "200", "100", "xMinYMax slice", vec{0, 0}, vec{0, -100}},
+		{"0 0 100 100", "200", "100", "xMidYMid slice", vec{50, 50}, vec{100, 50}},
+		{"0 0 100 100", "200", "100", "none", vec{100, 100}, vec{200, 100}},
+		{"0 0 100 100", "200", "100", "defer none", vec{100, 100}, vec{200, 100}},
+	}
+	for _, tt := range tests {
+		attrs := map[string]string{"preserveAspectRatio": tt.aspect}
+		for k, v := range map[string]string{"viewBox": tt.viewBox, "width": tt.width, "height": tt.height} {
+			if v != "" {
+				attrs[k] = v
+			}
+		}
+		if got := viewBoxTransform(attrs).apply(tt.p); !nearVec(got, tt.to) {
+			t.Errorf("viewBoxTransform(%v) maps %v to %v, want %v", attrs, tt.p, got, tt.to)
+		}
+	}
+}
+
+func TestImportSVG(t *testing.T) {
+	const doc = `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100" viewBox="0 0 50 50">
+  <defs><circle r="5"/><g><path d="M0 0 L1 1"/></g></defs>
+  <g transform="translate(10 0)" stroke="red" stroke-width="2">
+    <path d="M0 0 L10 0"/>
+    <g transform="scale(2)"><path d="M0 0 L0 5" fill="none"/></g>
+  </g>
+  <path d="M0 0 L5 5" style="fill:#00f8"/>
+</svg>`
+	shapes, err := importSVG(strings.NewReader(doc), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	want := []struct {
+		stroke, fill color.Color
+		width        float64
+		pts          []vec
+	}{
+		// the viewBox doubles everything, then the world has y up
+		{red, color.Black, 4, []vec{{19.5, 99.5}, {39.5, 99.5}}},
+		{red, nil, 8, []vec{{19.5, 99.5}, {19.5, 79.5}}},
+		{nil, color.NRGBA{0, 0, 0xff, 0x88}, 2, []vec{{-0.5, 99.5}, {9.5, 89.5}}},
+	}
+	if len(shapes) != len(want) {
+		t.Fatalf("%d shapes, want %d", len(shapes), len(want))
+	}
+	for i, sh := range shapes {
+		w := want[i]
+		if !sameColor(sh.stroke, w.stroke) || !sameColor(sh.fill, w.fill) || math.Abs(sh.width-w.width) > 1e-9 {
+			t.Errorf("shape %d: stroke %v, fill %v, width %g, want %v, %v, %g", i, sh.stroke, sh.fill, sh.width, w.stroke, w.fill, w.width)
+		}
+		_, pts := ends(sh.cmds)
+		if len(pts) != len(w.pts) || !nearVec(pts[0], w.pts[0]) || !nearVec(pts[1], w.pts[1]) {
+			t.Errorf("shape %d: points %v, want %v", i, pts, w.pts)
+		}
+	}
+}
+
+// sameColor reports whether c and d are both none or the same color.
+func sameColor(c, d color.Color) bool {
+	if c == nil || d == nil {
+		return c == nil && d == nil
+	}
+	return color.NRGBAModel.Convert(c) == color.NRGBAModel.Convert(d)
+}
+
+func TestParseSVGColor(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.Color
+	}{
+		{"#f00", color.NRGBA{0xff, 0, 0, 0xff}},
+		{"#F008", color.NRGBA{0xff, 0, 0, 0x88}},
+		{"#00ff00", color.NRGBA{0, 0xff, 0, 0xff}},
+		{"#0000ff80", color.NRGBA{0, 0, 0xff, 0x80}},
+		{"rgb(255, 128, 0)", color.NRGBA{0xff, 0x80, 0, 0xff}},
+		{"rgb(100%,0%,20%)", color.NRGBA{0xff, 0, 51, 0xff}},
+		{" Red ", color.NRGBA{0xff, 0, 0, 0xff}},
+		{"none", nil},
+		{"transparent", nil},
+		{"url(#g1)", nil},
+		// what it does not understand is black
+		{"#12345", color.Black},
+		{"#ggg", color.Black},
+		{"blurple", color.Black},
+	}
+	for _, tt := range tests {
+		if got := parseSVGColor(tt.s); !sameColor(got, tt.want) {
+			t.Errorf("parseSVGColor(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// textAlign is the horizontal alignment of text around the turtle.
+type textAlign int
+
+const (
+	alignLeft textAlign = iota
+	alignCenter
+	alignRight
+)
+
+// textFont selects the face and size text is written with.
+type textFont struct {
+	face string  // name of a built-in or loaded face
+	size float64 // pixels per em
+}
+
+// fontFaces holds the TTF data of the built-in faces.
+var fontFaces = map[string][]byte{
+	"Go":           goregular.TTF,
+	"Go Bold":      gobold.TTF,
+	"Go Italic":    goitalic.TTF,
+	"Go Medium":    gomedium.TTF,
+	"Go Mono":      gomono.TTF,
+	"Go Mono Bold": gomonobold.TTF,
+}
+
+var (
+	parsedFacesMu sync.Mutex
+	parsedFaces   = map[string]*sfnt.Font{}
+)
+
+// fontFace returns the built-in or loaded face called name.
+func fontFace(name string) (*sfnt.Font, error) {
+	parsedFacesMu.Lock()
+	defer parsedFacesMu.Unlock()
+	if f, ok := parsedFaces[name]; ok {
+		return f, nil
+	}
+	data, ok := fontFaces[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown font face %q", name)
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("font face %q: %v", name, err)
+	}
+	parsedFaces[name] = f
+	return f, nil
+}
+
+// Write draws s with the pen color, opacity and blend mode, its baseline
+// starting at, centered on or ending at the turtle position depending on
+// align. Runes missing from the face are taken from the fallback faces.
+// The turtle does not move.
+func (a *artist) Write(s string, f textFont, align textAlign) error {
+	chain, err := fontChain(f.face)
+	if err != nil {
+		return err
+	}
+	mask, origin, err := rasterizeText(chain, s, f.size)
+	if err != nil {
+		return err
+	}
+
+	// origin is the start of the baseline inside mask
+	w := float64(mask.Bounds().Dx())
+	x := a.X
+	switch align {
+	case alignCenter:
+		x -= w / 2
+	case alignRight:
+		x -= w
+	}
+	// the y in the reference frame of the image, as in World.setPoint
+	at := image.Pt(int(math.Round(x)), a.W.Height-1-int(math.Round(a.Y))).Sub(origin)
+	a.fillMask(mask, at)
+	a.recordText(s, f, align, mask.Bounds().Add(at))
+	return nil
+}
+
+// rasterizeText renders s at size pixels per em to a coverage mask, taking
+// each rune from the first face of chain that has it. It also returns the
+// start of the baseline within the mask.
+func rasterizeText(chain []*sfnt.Font, s string, size float64) (*image.Alpha, image.Point, error) {
+	var buf sfnt.Buffer
+	ppem := fixed.Int26_6(size * 64)
+
+	// lay the glyphs out along the baseline
+	type placed struct {
+		x    fixed.Int26_6
+		segs sfnt.Segments
+	}
+	var glyphs []placed
+	var x, ascent, descent fixed.Int26_6
+	var prevFace *sfnt.Font
+	prev := sfnt.GlyphIndex(0)
+	for _, r := range s {
+		face := faceFor(&buf, chain, r)
+		idx, err := face.GlyphIndex(&buf, r)
+		if err != nil {
+			return nil, image.Point{}, err
+		}
+		if face != prevFace {
+			metrics, err := face.Metrics(&buf, ppem, font.HintingNone)
+			if err != nil {
+				return nil, image.Point{}, err
+			}
+			if metrics.Ascent > ascent {
+				ascent = metrics.Ascent
+			}
+			if metrics.Descent > descent {
+				descent = metrics.Descent
+			}
+		} else if k, err := face.Kern(&buf, prev, idx, ppem, font.HintingNone); err == nil {
+			x += k
+		}
+		segs, err := face.LoadGlyph(&buf, idx, ppem, nil)
+		if err != nil {
+			return nil, image.Point{}, err
+		}
+		// LoadGlyph reuses buf, keep a copy
+		glyphs = append(glyphs, placed{x, append(sfnt.Segments(nil), segs...)})
+		adv, err := face.GlyphAdvance(&buf, idx, ppem, font.HintingNone)
+		if err != nil {
+			return nil, image.Point{}, err
+		}
+		x += adv
+		prev, prevFace = idx, face
+	}
+
+	w, h := x.Ceil(), ascent.Ceil()+descent.Ceil()
+	if w <= 0 || h <= 0 {
+		return image.NewAlpha(image.Rect(0, 0, 0, 0)), image.Point{}, nil
+	}
+
+	z := vector.NewRasterizer(w, h)
+	for _, g := range glyphs {
+		dx, dy := fix2f(g.x), float32(ascent.Ceil())
+		pt := func(p fixed.Point26_6) (float32, float32) {
+			return fix2f(p.X) + dx, fix2f(p.Y) + dy
+		}
+		for _, seg := range g.segs {
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				z.ClosePath()
+				z.MoveTo(pt(seg.Args[0]))
+			case sfnt.SegmentOpLineTo:
+				z.LineTo(pt(seg.Args[0]))
+			case sfnt.SegmentOpQuadTo:
+				bx, by := pt(seg.Args[0])
+				cx, cy := pt(seg.Args[1])
+				z.QuadTo(bx, by, cx, cy)
+			case sfnt.SegmentOpCubeTo:
+				bx, by := pt(seg.Args[0])
+				cx, cy := pt(seg.Args[1])
+				ex, ey := pt(seg.Args[2])
+				z.CubeTo(bx, by, cx, cy, ex, ey)
+			}
+		}
+		z.ClosePath()
+	}
+	mask := image.NewAlpha(z.Bounds())
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask, image.Pt(0, ascent.Ceil()), nil
+}
+
+func fix2f(v fixed.Int26_6) float32 {
+	return float32(v) / 64
+}
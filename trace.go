@@ -0,0 +1,486 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// traceMode is what the tracer follows in an image.
+type traceMode int
+
+const (
+	// traceRegions quantizes the colors of the image and follows the
+	// boundaries between regions of different colors.
+	traceRegions traceMode = iota
+	// traceEdges follows the sharp changes of brightness.
+	traceEdges
+)
+
+var traceModeNames = map[string]traceMode{
+	"regions": traceRegions,
+	"edges":   traceEdges,
+}
+
+// traceOptions are the knobs of the tracer.
+type traceOptions struct {
+	mode      traceMode
+	colors    int     // number of colors regions are quantized to
+	tolerance float64 // how far, in pixels, simplified lines may stray
+	width     float64 // pen width of the lines
+	minLength float64 // shorter lines are dropped as noise
+}
+
+var defaultTraceOptions = traceOptions{
+	mode:      traceRegions,
+	colors:    4,
+	tolerance: 1.5,
+	width:     1.5,
+	minLength: 6,
+}
+
+// traceLine is a polyline found in the image, in image pixels, with the
+// color it is drawn with.
+type traceLine struct {
+	color  color.Color
+	points []vec
+}
+
+// traceImage vectorizes img, scaled to fit box in world coordinates, into
+// shapes drawn one after the other.
+func traceImage(img image.Image, box image.Rectangle, opt traceOptions) []*importedShape {
+	b := img.Bounds()
+	if b.Empty() || box.Empty() {
+		return nil
+	}
+	scale := math.Min(float64(box.Dx())/float64(b.Dx()), float64(box.Dy())/float64(b.Dy()))
+	w := int(math.Max(1, math.Round(float64(b.Dx())*scale)))
+	h := int(math.Max(1, math.Round(float64(b.Dy())*scale)))
+	px := resample(img, w, h)
+
+	var lines []traceLine
+	switch opt.mode {
+	case traceEdges:
+		lines = edgeLines(px, w, h)
+	default:
+		lines = regionLines(px, w, h, opt.colors)
+	}
+
+	var kept []traceLine
+	for _, l := range lines {
+		l.points = douglasPeucker(l.points, opt.tolerance)
+		if polylineLength(l.points) >= opt.minLength {
+			kept = append(kept, l)
+		}
+	}
+	kept = orderLines(kept)
+
+	// center the image in the box; image rows go down, world y goes up
+	ox := float64(box.Min.X) + float64(box.Dx()-w)/2
+	oy := float64(box.Max.Y) - float64(box.Dy()-h)/2
+	shapes := make([]*importedShape, len(kept))
+	for i, l := range kept {
+		sh := &importedShape{stroke: l.color, width: opt.width}
+		for j, p := range l.points {
+			op := opLine
+			if j == 0 {
+				op = opMove
+			}
+			sh.cmds = append(sh.cmds, pathCmd{op, []vec{{ox + p.x, oy - p.y}}})
+		}
+		shapes[i] = sh
+	}
+	return shapes
+}
+
+// traceImageFile traces the PNG or JPEG image at path.
+func traceImageFile(path string, box image.Rectangle, opt traceOptions) ([]*importedShape, error) {
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	return traceImage(img, box, opt), nil
+}
+
+// resample scales img to w by h pixels, averaging the pixels each one
+// covers, over a white background.
+func resample(img image.Image, w, h int) []color.NRGBA {
+	b := img.Bounds()
+	out := make([]color.NRGBA, w*h)
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, n float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// premultiplied, so adding the white left uncovered
+					white := float64(0xffff - ca)
+					r += float64(cr) + white
+					g += float64(cg) + white
+					bl += float64(cb) + white
+					n++
+				}
+			}
+			k := n * 0x101
+			out[y*w+x] = color.NRGBA{uint8(r / k), uint8(g / k), uint8(bl / k), 0xff}
+		}
+	}
+	return out
+}
+
+// quantize reduces the colors of px to at most k with k-means, and returns
+// the palette and the palette index of each pixel.
+func quantize(px []color.NRGBA, k int) ([]color.NRGBA, []int) {
+	if k < 2 {
+		k = 2
+	}
+	// start from colors spread over the range of brightness
+	sorted := append([]color.NRGBA(nil), px...)
+	sort.Slice(sorted, func(i, j int) bool { return luma(sorted[i]) < luma(sorted[j]) })
+	centers := make([][3]float64, k)
+	for i := range centers {
+		c := sorted[(2*i+1)*len(sorted)/(2*k)]
+		centers[i] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+
+	labels := make([]int, len(px))
+	for iter := 0; iter < 12; iter++ {
+		sums := make([][4]float64, k)
+		changed := false
+		for i, c := range px {
+			best, bestD := 0, math.Inf(1)
+			for j, m := range centers {
+				dr, dg, db := float64(c.R)-m[0], float64(c.G)-m[1], float64(c.B)-m[2]
+				if d := dr*dr + dg*dg + db*db; d < bestD {
+					best, bestD = j, d
+				}
+			}
+			if labels[i] != best {
+				labels[i], changed = best, true
+			}
+			s := &sums[best]
+			s[0] += float64(c.R)
+			s[1] += float64(c.G)
+			s[2] += float64(c.B)
+			s[3]++
+		}
+		for j, s := range sums {
+			if s[3] > 0 {
+				centers[j] = [3]float64{s[0] / s[3], s[1] / s[3], s[2] / s[3]}
+			}
+		}
+		if !changed && iter > 0 {
+			break
+		}
+	}
+	palette := make([]color.NRGBA, k)
+	for j, m := range centers {
+		palette[j] = color.NRGBA{uint8(math.Round(m[0])), uint8(math.Round(m[1])), uint8(math.Round(m[2])), 0xff}
+	}
+	return palette, labels
+}
+
+func luma(c color.NRGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+// majority replaces each label by the most common one around it, which
+// removes specks of a single pixel or two.
+func majority(labels []int, w, h, k int) []int {
+	out := make([]int, len(labels))
+	count := make([]int, k)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for i := range count {
+				count[i] = 0
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if nx, ny := x+dx, y+dy; nx >= 0 && nx < w && ny >= 0 && ny < h {
+						count[labels[ny*w+nx]]++
+					}
+				}
+			}
+			best := labels[y*w+x]
+			for i, c := range count {
+				if c > count[best] {
+					best = i
+				}
+			}
+			out[y*w+x] = best
+		}
+	}
+	return out
+}
+
+// regionLines quantizes px to k colors and returns the boundaries between
+// regions, on the pixel grid. Each boundary is drawn in the darker of the
+// colors it separates.
+func regionLines(px []color.NRGBA, w, h, k int) []traceLine {
+	palette, labels := quantize(px, k)
+	labels = majority(labels, w, h, len(palette))
+
+	// the grid edges between pixels of different labels, keyed by the
+	// color they are drawn with
+	edges := map[int][][2]image.Point{}
+	darker := func(i, j int) int {
+		if luma(palette[i]) <= luma(palette[j]) {
+			return i
+		}
+		return j
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			l := labels[y*w+x]
+			if x+1 < w {
+				if r := labels[y*w+x+1]; r != l {
+					c := darker(l, r)
+					edges[c] = append(edges[c], [2]image.Point{image.Pt(x+1, y), image.Pt(x+1, y+1)})
+				}
+			}
+			if y+1 < h {
+				if d := labels[(y+1)*w+x]; d != l {
+					c := darker(l, d)
+					edges[c] = append(edges[c], [2]image.Point{image.Pt(x, y+1), image.Pt(x+1, y+1)})
+				}
+			}
+		}
+	}
+
+	var lines []traceLine
+	for c := range palette {
+		for _, pts := range chainSegments(edges[c]) {
+			lines = append(lines, traceLine{color: palette[c], points: pts})
+		}
+	}
+	return lines
+}
+
+// chainSegments joins segments sharing end points into polylines, breaking
+// them where more than two segments meet.
+func chainSegments(segs [][2]image.Point) [][]vec {
+	at := map[image.Point][]int{}
+	for i, s := range segs {
+		at[s[0]] = append(at[s[0]], i)
+		at[s[1]] = append(at[s[1]], i)
+	}
+	used := make([]bool, len(segs))
+	walk := func(start image.Point, first int) []vec {
+		pts := []vec{{float64(start.X), float64(start.Y)}}
+		p, i := start, first
+		for {
+			used[i] = true
+			s := segs[i]
+			if s[0] == p {
+				p = s[1]
+			} else {
+				p = s[0]
+			}
+			pts = append(pts, vec{float64(p.X), float64(p.Y)})
+			next := -1
+			if len(at[p]) == 2 {
+				for _, j := range at[p] {
+					if !used[j] {
+						next = j
+					}
+				}
+			}
+			if next < 0 {
+				return pts
+			}
+			i = next
+		}
+	}
+
+	var lines [][]vec
+	// open lines first, from their ends or junctions, then closed loops;
+	// points are visited in a fixed order so tracing is repeatable
+	var points []image.Point
+	for p := range at {
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Y != points[j].Y {
+			return points[i].Y < points[j].Y
+		}
+		return points[i].X < points[j].X
+	})
+	for _, p := range points {
+		if len(at[p]) == 2 {
+			continue
+		}
+		for _, i := range at[p] {
+			if !used[i] {
+				lines = append(lines, walk(p, i))
+			}
+		}
+	}
+	for _, p := range points {
+		for _, i := range at[p] {
+			if !used[i] {
+				lines = append(lines, walk(p, i))
+			}
+		}
+	}
+	return lines
+}
+
+// edgeLines finds the edges of px with a Sobel filter thinned to one pixel,
+// and returns them as polylines through the centers of their pixels.
+func edgeLines(px []color.NRGBA, w, h int) []traceLine {
+	lum := make([]float64, len(px))
+	for i, c := range px {
+		lum[i] = luma(c)
+	}
+	at := func(x, y int) float64 {
+		x = int(math.Max(0, math.Min(float64(w-1), float64(x))))
+		y = int(math.Max(0, math.Min(float64(h-1), float64(y))))
+		return lum[y*w+x]
+	}
+	mag := make([]float64, len(px))
+	dir := make([]float64, len(px))
+	max := 0.0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			m := math.Hypot(gx, gy)
+			mag[y*w+x], dir[y*w+x] = m, math.Atan2(gy, gx)
+			max = math.Max(max, m)
+		}
+	}
+	if max == 0 {
+		return nil
+	}
+
+	// keep the pixels stronger than their neighbours across the edge
+	threshold := max * 0.2
+	edge := make([]bool, len(px))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m := mag[y*w+x]
+			if m < threshold {
+				continue
+			}
+			dx := int(math.Round(math.Cos(dir[y*w+x])))
+			dy := int(math.Round(math.Sin(dir[y*w+x])))
+			m1, m2 := 0.0, 0.0
+			if nx, ny := x+dx, y+dy; nx >= 0 && nx < w && ny >= 0 && ny < h {
+				m1 = mag[ny*w+nx]
+			}
+			if nx, ny := x-dx, y-dy; nx >= 0 && nx < w && ny >= 0 && ny < h {
+				m2 = mag[ny*w+nx]
+			}
+			edge[y*w+x] = m >= m1 && m >= m2
+		}
+	}
+
+	// chain the edge pixels, starting from line ends
+	neighbours := func(x, y int) []image.Point {
+		var ns []image.Point
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := x+dx, y+dy
+				if (dx != 0 || dy != 0) && nx >= 0 && nx < w && ny >= 0 && ny < h && edge[ny*w+nx] {
+					ns = append(ns, image.Pt(nx, ny))
+				}
+			}
+		}
+		return ns
+	}
+	var lines []traceLine
+	walk := func(x, y int) {
+		var pts []vec
+		for {
+			edge[y*w+x] = false
+			pts = append(pts, vec{float64(x) + 0.5, float64(y) + 0.5})
+			ns := neighbours(x, y)
+			if len(ns) == 0 {
+				break
+			}
+			x, y = ns[0].X, ns[0].Y
+		}
+		lines = append(lines, traceLine{color: color.Black, points: pts})
+	}
+	for pass := 0; pass < 2; pass++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if edge[y*w+x] && (pass == 1 || len(neighbours(x, y)) == 1) {
+					walk(x, y)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+func polylineLength(points []vec) float64 {
+	l := 0.0
+	for i := 1; i < len(points); i++ {
+		l += points[i].sub(points[i-1]).length()
+	}
+	return l
+}
+
+// orderLines orders lines so that the pen travels little between them:
+// each next line is the one starting or ending closest to where the pen
+// is, reversed if needed.
+func orderLines(lines []traceLine) []traceLine {
+	var out []traceLine
+	used := make([]bool, len(lines))
+	pen := vec{}
+	for range lines {
+		best, bestD, reverse := -1, math.Inf(1), false
+		for i, l := range lines {
+			if used[i] {
+				continue
+			}
+			if d := l.points[0].sub(pen).length(); d < bestD {
+				best, bestD, reverse = i, d, false
+			}
+			if d := l.points[len(l.points)-1].sub(pen).length(); d < bestD {
+				best, bestD, reverse = i, d, true
+			}
+		}
+		used[best] = true
+		l := lines[best]
+		if reverse {
+			pts := make([]vec, len(l.points))
+			for i, p := range l.points {
+				pts[len(pts)-1-i] = p
+			}
+			l.points = pts
+		}
+		out = append(out, l)
+		pen = l.points[len(l.points)-1]
+	}
+	return out
+}
+
+// addTracedPart adds a part drawing the lines traced from an image, on a
+// layer named trace.
+func addTracedPart(path string, opt traceOptions) error {
+	margin := 20
+	box := image.Rect(margin, margin, int(width)-margin, int(hight)-margin)
+	shapes, err := traceImageFile(path, box, opt)
+	if err != nil {
+		return err
+	}
+	addPart("trace", func(t *artist) {
+		for _, sh := range shapes {
+			sh.draw(t)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package turtlerpc is the client of the JSON-RPC control socket of bdd-go,
+// started with the -rpc flag, which lets other programs draw with the
+// turtle in the window.
+//
+// The socket speaks JSON-RPC 1.0, one object per request, as the
+// net/rpc/jsonrpc package does, so clients in other languages need no
+// more than a JSON encoder. The methods are Turtle.Command, Turtle.Reset,
+// Turtle.Snapshot and Turtle.Events, with the argument and reply types of
+// this package.
+package turtlerpc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"time"
+)
+
+// CommandArgs are the arguments of Turtle.Command: lines in the language
+// of scene files, run in order.
+type CommandArgs struct {
+	Lines []string `json:"lines"`
+}
+
+// CommandReply is the reply of Turtle.Command.
+type CommandReply struct {
+	Run int `json:"run"` // how many lines were run
+}
+
+// SnapshotReply is the reply of Turtle.Snapshot.
+type SnapshotReply struct {
+	PNG []byte `json:"png"` // the drawing shown in the window, base64 in JSON
+}
+
+// EventsArgs are the arguments of Turtle.Events.
+type EventsArgs struct {
+	After  int `json:"after"`   // the sequence number of the last event seen
+	WaitMs int `json:"wait_ms"` // how long to wait for an event when there is none
+}
+
+// EventsReply is the reply of Turtle.Events.
+type EventsReply struct {
+	Events []Event `json:"events"`
+}
+
+// Event reports the progress of the drawing.
+type Event struct {
+	Seq   int    `json:"seq"`
+	Kind  string `json:"kind"`  // start, done or error of a command, or reset
+	Line  string `json:"line"`  // the command
+	Error string `json:"error"` // why an error event failed
+}
+
+// Empty is the argument and reply of the methods that have none.
+type Empty struct{}
+
+// SplitAddr returns the network and address of a socket address given as
+// unix:path for a Unix domain socket, or host:port for TCP.
+func SplitAddr(addr string) (network, address string) {
+	if strings.HasPrefix(addr, "unix:") {
+		return "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	return "tcp", addr
+}
+
+// Client calls the methods of the control socket.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects to the control socket at addr, as given to SplitAddr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial(SplitAddr(addr))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a client talking over conn.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{rpc: jsonrpc.NewClient(conn)}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Command runs lines of turtle commands, such as "fd 50" or "color red",
+// in order, and returns once they are drawn. It stops at the first line
+// that fails.
+func (c *Client) Command(lines ...string) error {
+	var reply CommandReply
+	return c.rpc.Call("Turtle.Command", CommandArgs{Lines: lines}, &reply)
+}
+
+// Reset erases what the clients drew and starts over with a new turtle.
+func (c *Client) Reset() error {
+	return c.rpc.Call("Turtle.Reset", Empty{}, &Empty{})
+}
+
+// Snapshot returns the drawing shown in the window.
+func (c *Client) Snapshot() (image.Image, error) {
+	var reply SnapshotReply
+	if err := c.rpc.Call("Turtle.Snapshot", Empty{}, &reply); err != nil {
+		return nil, err
+	}
+	m, err := png.Decode(bytes.NewReader(reply.PNG))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %v", err)
+	}
+	return m, nil
+}
+
+// Events returns the events after the one numbered after, waiting up to
+// wait for one if there are none yet.
+func (c *Client) Events(after int, wait time.Duration) ([]Event, error) {
+	var reply EventsReply
+	args := EventsArgs{After: after, WaitMs: int(wait / time.Millisecond)}
+	if err := c.rpc.Call("Turtle.Events", args, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Events, nil
+}